@@ -0,0 +1,65 @@
+package cache_test
+
+import (
+	"shortener/cache"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := cache.NewLRU(2)
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+
+	// "a" becomes the most recently used value
+	c.Get("a")
+	c.Set("c", 3, time.Minute)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "least recently used value should be evicted")
+
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Size)
+}
+
+func TestLRUExpiresValues(t *testing.T) {
+	c := cache.NewLRU(10)
+
+	c.Set("a", 1, 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok, "expired value should not be returned")
+	assert.Equal(t, 0, c.Stats().Size)
+}
+
+func TestLRUCountsHitsAndMisses(t *testing.T) {
+	c := cache.NewLRU(10)
+
+	c.Set("a", nil, time.Minute)
+	c.Get("a")
+	c.Get("b")
+	c.Delete("a")
+	c.Get("a")
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+}
+
+func TestLRUWithZeroCapacity(t *testing.T) {
+	c := cache.NewLRU(0)
+
+	c.Set("a", 1, time.Minute)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok, "cache without capacity should not store values")
+}
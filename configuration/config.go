@@ -3,6 +3,7 @@ package configuration
 import (
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -11,19 +12,222 @@ type configuration struct {
 	TokenSecret   string
 	AnonUserLogin string
 	TokenTTL      int
-	initialized   bool
+	// RefreshTokenTTL is a lifetime of the refresh token in seconds
+	RefreshTokenTTL int
+	CodeLength      int
+	CodeAlphabet    string
+	CodeMode        string
+	CodeSalt        string
+	CodeMaxRetries  int
+	// ExpiredLinkFallbackURL is used as a redirect target for the expired links.
+	// Error is returned when it is empty.
+	ExpiredLinkFallbackURL string
+	// LinkPasswordMaxAttempts is a count of failed attempts to enter password of the link
+	// which are allowed within LinkPasswordWindow (in seconds)
+	LinkPasswordMaxAttempts int
+	LinkPasswordWindow      int
+	// Failed logins are delayed with exponential backoff after LoginFreeAttempts per login
+	// and after LoginIPFreeAttempts per client IP. Login (IP) is locked after LoginLockoutAttempts
+	// (LoginIPLockoutAttempts) failures. Delays and durations are in seconds.
+	LoginFreeAttempts      int
+	LoginIPFreeAttempts    int
+	LoginBaseDelay         int
+	LoginMaxDelay          int
+	LoginLockoutAttempts   int
+	LoginIPLockoutAttempts int
+	LoginLockoutDuration   int
+	// TrustedProxies is a comma separated list of IPs and CIDRs which are allowed to set X-Forwarded-For header
+	TrustedProxies string
+	// CountryHeader is a header with client country code set by CDN (e.g. CF-IPCountry)
+	CountryHeader string
+	// Usages are saved in background by workers, see tracking package.
+	// UsageFlushInterval is in milliseconds, UsageQueuePolicy is "drop" or "block".
+	UsageQueueSize     int
+	UsageWorkers       int
+	UsageBatchSize     int
+	UsageFlushInterval int
+	UsageQueuePolicy   string
+	// Redirect lookups are cached. TTLs are in seconds, size 0 disables the cache
+	LinkCacheSize        int
+	LinkCacheTTL         int
+	LinkCacheNegativeTTL int
+	// HTTP server settings, timeouts are in seconds
+	ListenAddress   string
+	ReadTimeout     int
+	WriteTimeout    int
+	IdleTimeout     int
+	MaxHeaderBytes  int
+	ShutdownTimeout int
+	// LogLevel is one of logrus levels (e.g. debug, info, warn), LogFormat is "json" or "text"
+	LogLevel  string
+	LogFormat string
+	// PasswordResetTTL is a lifetime of the password reset token in seconds
+	PasswordResetTTL int
+	// Notifier delivers messages to the users (e.g. password reset tokens): "log", "file" or "smtp".
+	// Password reset is unavailable when it is empty. "log" and "file" notifiers expose tokens,
+	// so they should be used only for local development.
+	// NotifierFile is used by "file" notifier, SMTP settings are used by "smtp" notifier.
+	Notifier     string
+	NotifierFile string
+	SMTPAddress  string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// Quotas of the free plan, 0 disables the limit. Shared anonymous user has its own daily limit
+	QuotaMaxLinks           int
+	QuotaMaxLinksPerDay     int
+	QuotaMaxClicksPerMonth  int
+	QuotaAnonMaxLinksPerDay int
+	// Requests are limited by token buckets per user (per client IP for anonymous requests).
+	// Limits are counts of requests per RateLimitPeriod (in seconds), 0 disables the limit
+	RateLimitPeriod    int
+	RateLimitLinks     int
+	RateLimitRedirects int
+	RateLimitLogin     int
+	// GuestTTL is a lifetime of the guest cookie of the anonymous visitor in seconds,
+	// guests with their links are deleted when it is over
+	GuestTTL int
+	// WorkspaceInvitationTTL is a lifetime of the invitation to the workspace in seconds
+	WorkspaceInvitationTTL int
+	// AdminLogins are comma separated logins of the users which get admin role at startup,
+	// so the first administrator could be created
+	AdminLogins []string
+	initialized bool
 }
 
+const defaultRefreshTokenTTL = 30 * 24 * 60 * 60
+const defaultCodeLength = 7
+const defaultCodeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+const defaultCodeMode = "random"
+const defaultCodeMaxRetries = 5
+const defaultLinkPasswordMaxAttempts = 5
+const defaultLinkPasswordWindow = 300
+const defaultLoginFreeAttempts = 3
+const defaultLoginIPFreeAttempts = 20
+const defaultLoginBaseDelay = 1
+const defaultLoginMaxDelay = 60
+const defaultLoginLockoutAttempts = 10
+const defaultLoginIPLockoutAttempts = 100
+const defaultLoginLockoutDuration = 15 * 60
+const defaultUsageQueueSize = 10000
+const defaultUsageWorkers = 2
+const defaultUsageBatchSize = 500
+const defaultUsageFlushInterval = 1000
+const defaultUsageQueuePolicy = "drop"
+const defaultLinkCacheSize = 10000
+const defaultLinkCacheTTL = 60
+const defaultLinkCacheNegativeTTL = 10
+const defaultListenAddress = "127.0.0.1:8000"
+const defaultReadTimeout = 15
+const defaultWriteTimeout = 15
+const defaultIdleTimeout = 60
+const defaultMaxHeaderBytes = 1 << 20
+const defaultShutdownTimeout = 30
+const defaultLogLevel = "info"
+const defaultLogFormat = "json"
+const defaultPasswordResetTTL = 60 * 60
+const defaultWorkspaceInvitationTTL = 7 * 24 * 60 * 60
+const defaultQuotaMaxLinks = 1000
+const defaultQuotaMaxLinksPerDay = 100
+const defaultQuotaMaxClicksPerMonth = 100000
+const defaultQuotaAnonMaxLinksPerDay = 1000
+const defaultRateLimitPeriod = 60
+const defaultGuestTTL = 30 * 24 * 60 * 60
+const defaultRateLimitLinks = 30
+const defaultRateLimitRedirects = 600
+const defaultRateLimitLogin = 10
+
 var config configuration
 var once sync.Once
 var mutex sync.Mutex
 
+func lookupString(name string, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// lookupList returns comma separated values, empty values are skipped
+func lookupList(name string) []string {
+	values := make([]string, 0)
+
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func lookupInt(name string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return value
+	}
+
+	return defaultValue
+}
+
 func loadEnv() {
 	config.PostgreSQLUrl, _ = os.LookupEnv("SQL_DB_URL")
 	config.TokenSecret, _ = os.LookupEnv("JWT_TOKEN_SECRET")
 	config.AnonUserLogin, _ = os.LookupEnv("ANON_USER_LOGIN")
 	tokenTTL, _ := os.LookupEnv("TOKEN_TTL")
 	config.TokenTTL, _ = strconv.Atoi(tokenTTL)
+	config.RefreshTokenTTL = lookupInt("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+	config.CodeLength = lookupInt("CODE_LENGTH", defaultCodeLength)
+	config.CodeAlphabet = lookupString("CODE_ALPHABET", defaultCodeAlphabet)
+	config.CodeMode = lookupString("CODE_MODE", defaultCodeMode)
+	config.CodeSalt, _ = os.LookupEnv("CODE_SALT")
+	config.CodeMaxRetries = lookupInt("CODE_MAX_RETRIES", defaultCodeMaxRetries)
+	config.ExpiredLinkFallbackURL, _ = os.LookupEnv("EXPIRED_LINK_FALLBACK_URL")
+	config.LinkPasswordMaxAttempts = lookupInt("LINK_PASSWORD_MAX_ATTEMPTS", defaultLinkPasswordMaxAttempts)
+	config.LinkPasswordWindow = lookupInt("LINK_PASSWORD_WINDOW", defaultLinkPasswordWindow)
+	config.LoginFreeAttempts = lookupInt("LOGIN_FREE_ATTEMPTS", defaultLoginFreeAttempts)
+	config.LoginIPFreeAttempts = lookupInt("LOGIN_IP_FREE_ATTEMPTS", defaultLoginIPFreeAttempts)
+	config.LoginBaseDelay = lookupInt("LOGIN_BASE_DELAY", defaultLoginBaseDelay)
+	config.LoginMaxDelay = lookupInt("LOGIN_MAX_DELAY", defaultLoginMaxDelay)
+	config.LoginLockoutAttempts = lookupInt("LOGIN_LOCKOUT_ATTEMPTS", defaultLoginLockoutAttempts)
+	config.LoginIPLockoutAttempts = lookupInt("LOGIN_IP_LOCKOUT_ATTEMPTS", defaultLoginIPLockoutAttempts)
+	config.LoginLockoutDuration = lookupInt("LOGIN_LOCKOUT_DURATION", defaultLoginLockoutDuration)
+	config.TrustedProxies, _ = os.LookupEnv("TRUSTED_PROXIES")
+	config.CountryHeader, _ = os.LookupEnv("COUNTRY_HEADER")
+	config.UsageQueueSize = lookupInt("USAGE_QUEUE_SIZE", defaultUsageQueueSize)
+	config.UsageWorkers = lookupInt("USAGE_WORKERS", defaultUsageWorkers)
+	config.UsageBatchSize = lookupInt("USAGE_BATCH_SIZE", defaultUsageBatchSize)
+	config.UsageFlushInterval = lookupInt("USAGE_FLUSH_INTERVAL", defaultUsageFlushInterval)
+	config.UsageQueuePolicy = lookupString("USAGE_QUEUE_POLICY", defaultUsageQueuePolicy)
+	config.LinkCacheSize = lookupInt("LINK_CACHE_SIZE", defaultLinkCacheSize)
+	config.LinkCacheTTL = lookupInt("LINK_CACHE_TTL", defaultLinkCacheTTL)
+	config.LinkCacheNegativeTTL = lookupInt("LINK_CACHE_NEGATIVE_TTL", defaultLinkCacheNegativeTTL)
+	config.ListenAddress = lookupString("LISTEN_ADDRESS", defaultListenAddress)
+	config.ReadTimeout = lookupInt("READ_TIMEOUT", defaultReadTimeout)
+	config.WriteTimeout = lookupInt("WRITE_TIMEOUT", defaultWriteTimeout)
+	config.IdleTimeout = lookupInt("IDLE_TIMEOUT", defaultIdleTimeout)
+	config.MaxHeaderBytes = lookupInt("MAX_HEADER_BYTES", defaultMaxHeaderBytes)
+	config.ShutdownTimeout = lookupInt("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	config.LogLevel = lookupString("LOG_LEVEL", defaultLogLevel)
+	config.LogFormat = lookupString("LOG_FORMAT", defaultLogFormat)
+	config.PasswordResetTTL = lookupInt("PASSWORD_RESET_TTL", defaultPasswordResetTTL)
+	config.Notifier, _ = os.LookupEnv("NOTIFIER")
+	config.NotifierFile, _ = os.LookupEnv("NOTIFIER_FILE")
+	config.SMTPAddress, _ = os.LookupEnv("SMTP_ADDRESS")
+	config.SMTPUsername, _ = os.LookupEnv("SMTP_USERNAME")
+	config.SMTPPassword, _ = os.LookupEnv("SMTP_PASSWORD")
+	config.SMTPFrom, _ = os.LookupEnv("SMTP_FROM")
+	config.QuotaMaxLinks = lookupInt("QUOTA_MAX_LINKS", defaultQuotaMaxLinks)
+	config.QuotaMaxLinksPerDay = lookupInt("QUOTA_MAX_LINKS_PER_DAY", defaultQuotaMaxLinksPerDay)
+	config.QuotaMaxClicksPerMonth = lookupInt("QUOTA_MAX_CLICKS_PER_MONTH", defaultQuotaMaxClicksPerMonth)
+	config.QuotaAnonMaxLinksPerDay = lookupInt("QUOTA_ANON_MAX_LINKS_PER_DAY", defaultQuotaAnonMaxLinksPerDay)
+	config.RateLimitPeriod = lookupInt("RATE_LIMIT_PERIOD", defaultRateLimitPeriod)
+	config.RateLimitLinks = lookupInt("RATE_LIMIT_LINKS", defaultRateLimitLinks)
+	config.RateLimitRedirects = lookupInt("RATE_LIMIT_REDIRECTS", defaultRateLimitRedirects)
+	config.RateLimitLogin = lookupInt("RATE_LIMIT_LOGIN", defaultRateLimitLogin)
+	config.GuestTTL = lookupInt("GUEST_TTL", defaultGuestTTL)
+	config.WorkspaceInvitationTTL = lookupInt("WORKSPACE_INVITATION_TTL", defaultWorkspaceInvitationTTL)
+	config.AdminLogins = lookupList("ADMIN_LOGINS")
 }
 
 // GetConfiguration from env
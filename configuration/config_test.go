@@ -40,3 +40,39 @@ func TestGetConfiguration(t *testing.T) {
 		t.Errorf("Token TTL is not specified. Expected: " + strconv.Itoa(tokenTTL) + " actual: " + strconv.Itoa(config.TokenTTL))
 	}
 }
+
+func TestServerConfiguration(t *testing.T) {
+	os.Setenv("LISTEN_ADDRESS", "0.0.0.0:9000")
+	os.Setenv("SHUTDOWN_TIMEOUT", "5")
+	os.Setenv("READ_TIMEOUT", "not a number")
+	defer os.Unsetenv("LISTEN_ADDRESS")
+	defer os.Unsetenv("SHUTDOWN_TIMEOUT")
+	defer os.Unsetenv("READ_TIMEOUT")
+
+	configuration.Reload()
+	config := configuration.GetConfiguration()
+
+	if config.ListenAddress != "0.0.0.0:9000" {
+		t.Errorf("Listen address is incorrect. Expected: 0.0.0.0:9000 actual: %s", config.ListenAddress)
+	}
+
+	if config.ShutdownTimeout != 5 {
+		t.Errorf("Shutdown timeout is incorrect. Expected: 5 actual: %d", config.ShutdownTimeout)
+	}
+
+	if config.ReadTimeout != 15 {
+		t.Errorf("Read timeout should fall back to the default value. Expected: 15 actual: %d", config.ReadTimeout)
+	}
+}
+
+func TestAdminLogins(t *testing.T) {
+	os.Setenv("ADMIN_LOGINS", "admin@example.com, ,root@example.com ")
+	defer os.Unsetenv("ADMIN_LOGINS")
+
+	configuration.Reload()
+	config := configuration.GetConfiguration()
+
+	if len(config.AdminLogins) != 2 || config.AdminLogins[0] != "admin@example.com" || config.AdminLogins[1] != "root@example.com" {
+		t.Errorf("Admin logins are incorrect. Expected: [admin@example.com root@example.com] actual: %v", config.AdminLogins)
+	}
+}
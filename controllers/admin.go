@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"shortener/logger"
+	"shortener/models"
+	"shortener/models/options"
+	"shortener/repository"
+	"shortener/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminController is used by administrators (and support) to moderate users and links.
+// Every action is recorded to the audit log. Audit write failures are only logged (see record),
+// so actions are not blocked, but they could be missing from the audit log.
+type AdminController struct {
+	userRepository  repository.UserRepositoryInterface
+	linkRepository  repository.LinksRepositoryInterface
+	auditRepository repository.AuditRepositoryInterface
+}
+
+// RoleRequest represents body of the role change
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+// PlanRequest represents body of the plan change
+type PlanRequest struct {
+	Plan string `json:"plan"`
+}
+
+// NewAdminController func returns AdminController object. Links repository is passed explicitly
+// because it could be shared (e.g. cached), so deleted links are evicted from the cache
+func NewAdminController(db *sql.DB, linkRepository repository.LinksRepositoryInterface) AdminController {
+	return AdminController{
+		userRepository:  repository.NewUserRepository(db),
+		linkRepository:  linkRepository,
+		auditRepository: repository.NewAuditRepository(db),
+	}
+}
+
+// record saves action of the administrator to the audit log. Action is already performed,
+// so failure is logged instead of being returned to the client
+func (controller *AdminController) record(r *http.Request, action string, targetType string, targetID string, details map[string]interface{}) {
+	entry := models.AuditEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+	}
+
+	if actor, err := models.NewUserFromContext(r.Context()); err == nil {
+		entry.ActorID = actor.ID
+	}
+
+	if _, err := controller.auditRepository.CreateWithContext(r.Context(), entry); err != nil {
+		logger.FromContext(r.Context()).WithError(err).WithField("action", action).Error("unable to record admin action")
+	}
+}
+
+// targetUserID returns id of the user which is changed by the administrator. Administrators can not
+// disable or demote themselves, otherwise the last administrator could lose access
+func targetUserID(r *http.Request) (string, error) {
+	id := mux.Vars(r)["id"]
+
+	if actor, err := models.NewUserFromContext(r.Context()); err != nil || actor.ID == id {
+		return "", repository.ErrSelfAdministration
+	}
+
+	return id, nil
+}
+
+// ListUsers returns users which login contains "q" query parameter using offset and limit
+func (controller *AdminController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	opts := options.NewOptionsFromContext(r.Context())
+	query := r.URL.Query().Get("q")
+
+	users, err := controller.userRepository.SearchWithContext(r.Context(), query, *opts)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	controller.record(r, models.AuditListUsers, models.AuditTargetUser, "", map[string]interface{}{"query": query})
+	utils.RespondWithJSON(&w, http.StatusOK, users)
+}
+
+// FetchUser returns user with links
+func (controller *AdminController) FetchUser(w http.ResponseWriter, r *http.Request) {
+	opts := options.NewOptionsFromContext(r.Context())
+	id := mux.Vars(r)["id"]
+
+	user, err := controller.userRepository.FindByIDWithContext(r.Context(), id, *opts)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	controller.record(r, models.AuditViewUser, models.AuditTargetUser, id, nil)
+	utils.RespondWithJSON(&w, http.StatusOK, user)
+}
+
+// DisableUser disables the user and revokes the sessions
+func (controller *AdminController) DisableUser(w http.ResponseWriter, r *http.Request) {
+	controller.setDisabled(w, r, true)
+}
+
+// EnableUser enables the disabled user
+func (controller *AdminController) EnableUser(w http.ResponseWriter, r *http.Request) {
+	controller.setDisabled(w, r, false)
+}
+
+func (controller *AdminController) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, err := targetUserID(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	user, err := controller.userRepository.SetDisabledWithContext(r.Context(), id, disabled)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	action := models.AuditEnableUser
+
+	if disabled {
+		action = models.AuditDisableUser
+	}
+
+	controller.record(r, action, models.AuditTargetUser, id, nil)
+	utils.RespondWithJSON(&w, http.StatusOK, user)
+}
+
+// UpdateRole changes role of the user
+func (controller *AdminController) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	var request RoleRequest
+
+	id, err := targetUserID(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = models.ValidateRole(request.Role); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	user, err := controller.userRepository.SetRoleWithContext(r.Context(), id, request.Role)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	controller.record(r, models.AuditChangeRole, models.AuditTargetUser, id, map[string]interface{}{"role": request.Role})
+	utils.RespondWithJSON(&w, http.StatusOK, user)
+}
+
+// UpdatePlan changes plan of the user
+func (controller *AdminController) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	var request PlanRequest
+
+	id, err := targetUserID(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = models.ValidatePlan(request.Plan); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	user, err := controller.userRepository.SetPlanWithContext(r.Context(), id, request.Plan)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	controller.record(r, models.AuditChangePlan, models.AuditTargetUser, id, map[string]interface{}{"plan": request.Plan})
+	utils.RespondWithJSON(&w, http.StatusOK, user)
+}
+
+// FetchLink returns any link without redirect
+func (controller *AdminController) FetchLink(w http.ResponseWriter, r *http.Request) {
+	link, err := controller.linkRepository.FindByIDWithContext(r.Context(), models.Link{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	link.CleanPrivateFields()
+
+	controller.record(r, models.AuditViewLink, models.AuditTargetLink, link.ID, nil)
+	utils.RespondWithJSON(&w, http.StatusOK, link)
+}
+
+// DeleteLink deletes any link
+func (controller *AdminController) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	link, err := controller.linkRepository.FindByIDWithContext(r.Context(), models.Link{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = controller.linkRepository.DeleteWithContext(r.Context(), *link); err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	controller.record(r, models.AuditDeleteLink, models.AuditTargetLink, link.ID, map[string]interface{}{
+		"url":    link.URL,
+		"userId": link.UserID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAudit returns audit log using offset and limit
+func (controller *AdminController) ListAudit(w http.ResponseWriter, r *http.Request) {
+	opts := options.NewOptionsFromContext(r.Context())
+
+	entries, err := controller.auditRepository.FindAllWithContext(r.Context(), *opts)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	controller.record(r, models.AuditListAudit, models.AuditTargetAudit, "", map[string]interface{}{
+		"limit":  opts.Limit,
+		"offset": opts.Offset,
+	})
+
+	utils.RespondWithJSON(&w, http.StatusOK, entries)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"shortener/models"
+	"shortener/repository"
+	"shortener/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// APIKeyController manages API keys of the user
+type APIKeyController struct {
+	apiKeyRepository repository.APIKeyRepositoryInterface
+}
+
+// NewAPIKeyController func returns APIKeyController object
+func NewAPIKeyController(db *sql.DB) APIKeyController {
+	return APIKeyController{
+		apiKeyRepository: repository.NewAPIKeyRepository(db),
+	}
+}
+
+// Create generates new API key. Plain key is returned only in this response
+func (controller *APIKeyController) Create(w http.ResponseWriter, r *http.Request) {
+	var key models.APIKey
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = key.Populate(r); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = key.Validate(); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	key.UserID = user.ID
+
+	if err = key.Generate(); err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	created, err := controller.apiKeyRepository.CreateWithContext(r.Context(), key)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusCreated, created)
+}
+
+// List returns active keys of the user without plain keys
+func (controller *APIKeyController) List(w http.ResponseWriter, r *http.Request) {
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	keys, err := controller.apiKeyRepository.FindAllByUserWithContext(r.Context(), *user)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, keys)
+}
+
+// Revoke disables the key, requests with it are rejected immediately
+func (controller *APIKeyController) Revoke(w http.ResponseWriter, r *http.Request) {
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	err = controller.apiKeyRepository.RevokeWithContext(r.Context(), models.APIKey{ID: mux.Vars(r)["id"], UserID: user.ID})
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
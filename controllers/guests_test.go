@@ -0,0 +1,151 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shortener/configuration"
+	"shortener/controllers"
+	"shortener/models"
+	"shortener/models/options"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"shortener/tracking"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const guestOwner = "test-guest-owner"
+
+func TestGuestFlows(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+	defer suite.GetDB().Exec("delete from users where login = $1", guestOwner)
+
+	usageWriter := tracking.NewWriter(repository.NewUsageRepository(suite.GetDB()), tracking.Config{
+		QueueSize:     100,
+		Workers:       1,
+		BatchSize:     10,
+		FlushInterval: 100 * time.Millisecond,
+		Policy:        tracking.BlockPolicy,
+	})
+	defer usageWriter.Close(context.Background())
+
+	users := repository.NewUserRepository(suite.GetDB())
+	linkRepository := repository.NewCachedLinkRepository(repository.NewSQLLinkRepository(suite.GetDB()))
+	linkController := controllers.NewLinkController(suite.GetDB(), linkRepository, usageWriter)
+	userController := controllers.NewUserController(suite.GetDB(), linkRepository)
+
+	anon, err := users.FindByLogin(configuration.GetConfiguration().AnonUserLogin)
+	require.Nil(t, err)
+
+	withUser := func(r *http.Request, user *models.User) *http.Request {
+		ctx := context.WithValue(r.Context(), "user", user)
+		ctx = context.WithValue(ctx, "options", options.Options{Limit: 25})
+
+		return r.WithContext(ctx)
+	}
+
+	// createGuestLink creates the link as the shared anonymous user and returns the guest from the cookie
+	createGuestLink := func(t *testing.T) (*models.User, *models.Link) {
+		body, _ := json.Marshal(models.Link{URL: "https://example.com"})
+		w := httptest.NewRecorder()
+		linkController.Create(w, withUser(httptest.NewRequest(http.MethodPost, "/l", bytes.NewBuffer(body)), anon))
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		link := new(models.Link)
+		require.Nil(t, json.NewDecoder(w.Body).Decode(link))
+
+		r := httptest.NewRequest(http.MethodGet, "/l", nil)
+
+		for _, cookie := range w.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+
+		guestID, ok := models.GuestIDFromRequest(r)
+		require.True(t, ok, "guest cookie should be set")
+
+		guest, err := users.FindGuest(guestID)
+		require.Nil(t, err)
+
+		return guest, link
+	}
+
+	listLinks := func(t *testing.T, user *models.User) []models.Link {
+		w := httptest.NewRecorder()
+		linkController.List(w, withUser(httptest.NewRequest(http.MethodGet, "/l", nil), user))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var links []models.Link
+		require.Nil(t, json.NewDecoder(w.Body).Decode(&links))
+
+		return links
+	}
+
+	firstGuest, firstLink := createGuestLink(t)
+	secondGuest, secondLink := createGuestLink(t)
+
+	t.Run("should list links of the guest only", func(t *testing.T) {
+		assert.NotEqual(t, firstGuest.ID, secondGuest.ID)
+
+		firstLinks := listLinks(t, firstGuest)
+		require.Len(t, firstLinks, 1)
+		assert.Equal(t, firstLink.ID, firstLinks[0].ID)
+
+		secondLinks := listLinks(t, secondGuest)
+		require.Len(t, secondLinks, 1)
+		assert.Equal(t, secondLink.ID, secondLinks[0].ID)
+
+		assert.Empty(t, listLinks(t, anon), "links should not be listed for the shared anonymous user")
+	})
+
+	request := controllers.UserRequest{Login: guestOwner, Password: "superman"}
+	owner := new(models.User)
+
+	t.Run("should claim links of the guest on signup", func(t *testing.T) {
+		// link is cached with the guest as the owner
+		cached, err := linkRepository.FindByID(models.Link{ID: firstLink.ID})
+		require.Nil(t, err)
+		assert.Equal(t, firstGuest.ID, cached.UserID)
+
+		body, _ := json.Marshal(request)
+		w := httptest.NewRecorder()
+		userController.Create(w, withUser(httptest.NewRequest(http.MethodPost, "/users", bytes.NewBuffer(body)), firstGuest))
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Nil(t, json.NewDecoder(w.Body).Decode(owner))
+
+		links := listLinks(t, owner)
+		require.Len(t, links, 1)
+		assert.Equal(t, firstLink.ID, links[0].ID)
+
+		claimed, err := linkRepository.FindByID(models.Link{ID: firstLink.ID})
+		require.Nil(t, err)
+		assert.Equal(t, owner.ID, claimed.UserID, "claimed link should be removed from the cache")
+
+		_, err = users.FindGuest(firstGuest.ID)
+		assert.Equal(t, repository.ErrUserNotFound, err)
+	})
+
+	t.Run("should claim links of the guest on login", func(t *testing.T) {
+		body, _ := json.Marshal(request)
+		w := httptest.NewRecorder()
+		userController.Authorize(w, withUser(httptest.NewRequest(http.MethodPost, "/users/token", bytes.NewBuffer(body)), secondGuest))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		links := listLinks(t, owner)
+		require.Len(t, links, 2)
+
+		_, err := users.FindGuest(secondGuest.ID)
+		assert.Equal(t, repository.ErrUserNotFound, err)
+	})
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"shortener/migrator"
+	"shortener/utils"
+	"strconv"
+	"time"
+)
+
+// HealthOK is a status of the healthy component
+const HealthOK = "ok"
+
+// HealthFailed is a status of the broken component
+const HealthFailed = "failed"
+
+// healthCheckTimeout limits time of every readiness check
+const healthCheckTimeout = 2 * time.Second
+
+// HealthController represents liveness and readiness probes
+type HealthController struct {
+	db              *sql.DB
+	expectedVersion uint
+}
+
+// ComponentHealth represents state of the single component
+type ComponentHealth struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HealthReport represents state of the application
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+// NewHealthController func returns HealthController object.
+// Expected schema version is taken from the migrations directory.
+func NewHealthController(db *sql.DB, migrationsDirectory string) (HealthController, error) {
+	version, err := migrator.ExpectedVersion(migrationsDirectory)
+
+	return HealthController{db: db, expectedVersion: version}, err
+}
+
+// Liveness reports that the process is able to serve requests
+func (controller *HealthController) Liveness(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(&w, http.StatusOK, HealthReport{Status: HealthOK})
+}
+
+// Readiness checks the database connection and the schema version
+func (controller *HealthController) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := HealthReport{
+		Status: HealthOK,
+		Components: map[string]ComponentHealth{
+			"database":   checkComponent(r.Context(), controller.checkDatabase),
+			"migrations": checkComponent(r.Context(), controller.checkMigrations),
+		},
+	}
+	status := http.StatusOK
+
+	for _, component := range report.Components {
+		if component.Status != HealthOK {
+			report.Status = HealthFailed
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	utils.RespondWithJSON(&w, status, report)
+}
+
+func checkComponent(ctx context.Context, check func(context.Context) error) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	health := ComponentHealth{Status: HealthOK, Duration: time.Since(start).String()}
+
+	if err != nil {
+		health.Status = HealthFailed
+		health.Error = err.Error()
+	}
+
+	return health
+}
+
+func (controller *HealthController) checkDatabase(ctx context.Context) error {
+	return controller.db.PingContext(ctx)
+}
+
+func (controller *HealthController) checkMigrations(ctx context.Context) error {
+	version, dirty, err := migrator.CurrentVersion(ctx, controller.db)
+
+	if err == sql.ErrNoRows {
+		return errors.New("Migrations are not applied")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return errors.New("Migration " + strconv.FormatUint(uint64(version), 10) + " is dirty")
+	}
+
+	if version != controller.expectedVersion {
+		return errors.New("Schema version is " + strconv.FormatUint(uint64(version), 10) +
+			", expected " + strconv.FormatUint(uint64(controller.expectedVersion), 10))
+	}
+
+	return nil
+}
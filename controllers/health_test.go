@@ -0,0 +1,47 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shortener/controllers"
+	testutils "shortener/testUtils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveness(t *testing.T) {
+	controller, err := controllers.NewHealthController(nil, "../migrations")
+	require.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	controller.Liveness(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestReadiness(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	controller, err := controllers.NewHealthController(suite.GetDB(), "../migrations")
+	require.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	controller.Readiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	report := new(controllers.HealthReport)
+	json.NewDecoder(w.Result().Body).Decode(&report)
+
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, controllers.HealthOK, report.Status)
+	assert.Equal(t, controllers.HealthOK, report.Components["database"].Status)
+	assert.Equal(t, controllers.HealthOK, report.Components["migrations"].Status)
+}
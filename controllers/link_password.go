@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"html/template"
+	"net/http"
+	"shortener/configuration"
+	"shortener/limiter"
+	"shortener/models"
+	"shortener/models/crypto"
+	"shortener/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkPasswordHeader could be used by API clients to pass password of the protected link
+const LinkPasswordHeader = "X-Link-Password"
+
+// linkPasswordField is a name of the form (or query) field with password of the protected link
+const linkPasswordField = "password"
+
+// passwordAttempts is shared by all link controllers, otherwise limits depend on the router
+var passwordAttempts = limiter.NewAttemptLimiter(
+	configuration.GetConfiguration().LinkPasswordMaxAttempts,
+	time.Duration(configuration.GetConfiguration().LinkPasswordWindow)*time.Second,
+)
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Protected link</title>
+</head>
+<body>
+	<form method="POST">
+		<p>This link is protected. Please enter the password to continue.</p>
+		{{if .}}<p style="color: red">{{.}}</p>{{end}}
+		<input type="password" name="` + linkPasswordField + `" autofocus required>
+		<button type="submit">Continue</button>
+	</form>
+</body>
+</html>
+`))
+
+func wantsJSON(r *http.Request) bool {
+	return r.Header.Get(LinkPasswordHeader) != "" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func respondWithPasswordError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if wantsJSON(r) {
+		utils.RespondWithError(&w, status, models.NewError(message))
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	passwordForm.Execute(w, message)
+}
+
+// checkLinkPassword validates password of the protected link. When password is missing or incorrect
+// the response is written and false is returned. Failed attempts are limited per link
+// and they are reset when the correct password is entered. Attempt is reserved before the password
+// is compared, so concurrent guesses could not exceed the limit.
+func checkLinkPassword(w http.ResponseWriter, r *http.Request, link *models.Link) bool {
+	password := r.Header.Get(LinkPasswordHeader)
+
+	if password == "" {
+		password = r.FormValue(linkPasswordField)
+	}
+
+	if password == "" {
+		respondWithPasswordError(w, r, http.StatusUnauthorized, "")
+		return false
+	}
+
+	if wait := passwordAttempts.Attempt(link.ID); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		respondWithPasswordError(w, r, http.StatusTooManyRequests, "Too many attempts, please try again later")
+		return false
+	}
+
+	// reserved attempt stays counted as a failure
+	if !crypto.ValidatePassword(password, link.Password) {
+		respondWithPasswordError(w, r, http.StatusUnauthorized, "Password is incorrect")
+		return false
+	}
+
+	// earlier typos of the visitor who knows the password do not lock the link
+	passwordAttempts.Reset(link.ID)
+
+	return true
+}
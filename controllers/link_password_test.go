@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"shortener/limiter"
+	"shortener/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckLinkPasswordResetsAttempts(t *testing.T) {
+	attempts := passwordAttempts
+	passwordAttempts = limiter.NewAttemptLimiter(2, time.Minute)
+	t.Cleanup(func() { passwordAttempts = attempts })
+
+	password, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.Nil(t, err)
+
+	link := &models.Link{ID: "link", Password: string(password)}
+
+	check := func(password string) int {
+		r := httptest.NewRequest(http.MethodGet, "/l/link", nil)
+		r.Header.Set(LinkPasswordHeader, password)
+		w := httptest.NewRecorder()
+
+		if checkLinkPassword(w, r, link) {
+			return http.StatusOK
+		}
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, check("wrong"))
+	assert.Equal(t, http.StatusOK, check("secret"))
+
+	// the failure before the correct password is not counted anymore
+	assert.Equal(t, http.StatusUnauthorized, check("wrong"))
+	assert.Equal(t, http.StatusUnauthorized, check("wrong"))
+	assert.Equal(t, http.StatusTooManyRequests, check("secret"))
+}
+
+func TestCheckLinkPasswordReservesAttempts(t *testing.T) {
+	const maxAttempts = 3
+
+	attempts := passwordAttempts
+	passwordAttempts = limiter.NewAttemptLimiter(maxAttempts, time.Minute)
+	t.Cleanup(func() { passwordAttempts = attempts })
+
+	password, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.Nil(t, err)
+
+	link := &models.Link{ID: "link", Password: string(password)}
+	codes := make(chan int, 20)
+	var guesses sync.WaitGroup
+
+	for i := 0; i < cap(codes); i++ {
+		guesses.Add(1)
+
+		go func() {
+			defer guesses.Done()
+
+			r := httptest.NewRequest(http.MethodGet, "/l/link", nil)
+			r.Header.Set(LinkPasswordHeader, "wrong")
+			w := httptest.NewRecorder()
+			checkLinkPassword(w, r, link)
+			codes <- w.Code
+		}()
+	}
+
+	guesses.Wait()
+	close(codes)
+
+	compared := 0
+
+	for code := range codes {
+		if code == http.StatusUnauthorized {
+			compared++
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, code)
+		}
+	}
+
+	assert.Equal(t, maxAttempts, compared, "only reserved attempts should reach the password compare")
+}
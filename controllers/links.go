@@ -2,28 +2,41 @@ package controllers
 
 import (
 	"database/sql"
-	"log"
 	"net/http"
+	"shortener/configuration"
+	"shortener/logger"
+	"shortener/metrics"
 	"shortener/models"
+	"shortener/models/crypto"
 	"shortener/models/options"
 	"shortener/repository"
+	"shortener/tracking"
 	"shortener/utils"
+	"time"
 
-	"github.com/davecgh/go-spew/spew"
 	"github.com/gorilla/mux"
 )
 
 // LinkController represent link repository
 type LinkController struct {
-	linkRepository  repository.LinksRepositoryInterface
-	usageRepository repository.UsageRepositoryInterface
+	linkRepository      repository.LinksRepositoryInterface
+	usageRepository     repository.UsageRepositoryInterface
+	quotaRepository     repository.QuotaRepositoryInterface
+	userRepository      repository.UserRepositoryInterface
+	workspaceRepository repository.WorkspaceRepositoryInterface
+	usageWriter         *tracking.Writer
 }
 
-// NewUserController func returns UserController object
-func NewLinkController(db *sql.DB) LinkController {
+// NewLinkController func returns LinkController object. Links repository is passed explicitly
+// because it could be shared (e.g. cached), usages are saved in background by the writer
+func NewLinkController(db *sql.DB, linkRepository repository.LinksRepositoryInterface, usageWriter *tracking.Writer) LinkController {
 	return LinkController{
-		linkRepository:  repository.NewSQLLinkRepository(db),
-		usageRepository: repository.NewUsageRepository(db),
+		linkRepository:      linkRepository,
+		usageRepository:     repository.NewUsageRepository(db),
+		quotaRepository:     repository.NewQuotaRepository(db),
+		userRepository:      repository.NewUserRepository(db),
+		workspaceRepository: repository.NewWorkspaceRepository(db),
+		usageWriter:         usageWriter,
 	}
 }
 
@@ -32,10 +45,16 @@ func (controller *LinkController) List(w http.ResponseWriter, r *http.Request) {
 	opts := options.NewOptionsFromContext(r.Context())
 	user, _ := models.NewUserFromContext(r.Context())
 
+	// links of the shared anonymous user belong to the visitors who created them before guests
+	if isSharedAnon(user) {
+		utils.RespondWithJSON(&w, http.StatusOK, []*models.Link{})
+		return
+	}
+
 	links, err := controller.linkRepository.FindAllByUserWithContext(r.Context(), *user, *opts)
 
 	if err != nil {
-		utils.RespondWithError(&w, http.StatusBadRequest, models.NewError("Id is not provided"))
+		utils.RespondWithDomainError(&w, err)
 		return
 	}
 
@@ -44,7 +63,6 @@ func (controller *LinkController) List(w http.ResponseWriter, r *http.Request) {
 
 // FetchByID redirects user to the link
 func (controller *LinkController) FetchByID(w http.ResponseWriter, r *http.Request) {
-	spew.Dump("--- mux.Vars(r) ---", mux.Vars(r))
 	id, ok := mux.Vars(r)["id"]
 
 	if !ok {
@@ -55,20 +73,60 @@ func (controller *LinkController) FetchByID(w http.ResponseWriter, r *http.Reque
 	link, err := controller.linkRepository.FindByIDWithContext(r.Context(), models.Link{ID: id})
 
 	if err != nil {
-		utils.RespondWithError(&w, http.StatusNotFound, models.NewError(err.Error()))
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if link.IsExpired(time.Now()) {
+		respondWithExpiredLink(w)
+		return
+	}
+
+	if link.Protected && !checkLinkPassword(w, r, link) {
 		return
 	}
 
-	go func() {
-		_, err := controller.usageRepository.Create(link.ID)
+	if link.MaxClicks > 0 {
+		ok, err := controller.linkRepository.ConsumeClickWithContext(r.Context(), *link)
+
 		if err != nil {
-			log.Println("--- error ---", err)
+			utils.RespondWithDomainError(&w, err)
+			return
 		}
-	}()
+
+		if !ok {
+			respondWithExpiredLink(w)
+			return
+		}
+	}
+
+	usage := models.NewUsageFromRequest(r, link.ID, utils.ClientIP(r))
+	if !controller.usageWriter.Write(r.Context(), usage) {
+		logger.FromContext(r.Context()).WithField("link_id", link.ID).Warn("usage is dropped")
+	}
+	metrics.Redirects.Inc()
+
+	// limited links should not be cached by browsers, otherwise limits are not applied
+	if link.ExpiresAt != nil || link.MaxClicks > 0 || link.Protected {
+		utils.RedirectTemporarily(&w, link.URL)
+		return
+	}
 
 	utils.RedirectToAnotherResource(&w, link.URL)
 }
 
+// respondWithExpiredLink redirects to the fallback url or returns 410 error
+func respondWithExpiredLink(w http.ResponseWriter) {
+	fallbackURL := configuration.GetConfiguration().ExpiredLinkFallbackURL
+
+	if fallbackURL != "" {
+		utils.RedirectTemporarily(&w, fallbackURL)
+		return
+	}
+
+	utils.RespondWithError(&w, http.StatusGone, models.NewErrorWithCode("link_expired", "Link is expired"))
+}
+
 // Create saves link to the database
 func (controller *LinkController) Create(w http.ResponseWriter, r *http.Request) {
 	var link models.Link
@@ -83,16 +141,223 @@ func (controller *LinkController) Create(w http.ResponseWriter, r *http.Request)
 	link.UserID = user.ID
 
 	if err != nil {
-		utils.RespondWithError(&w, http.StatusBadRequest, models.NewError(err.Error()))
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = link.Validate(); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
 		return
 	}
 
+	if link.WorkspaceID != "" {
+		if _, err = controller.findMember(r, link.WorkspaceID, models.WorkspaceEditor); err != nil {
+			utils.RespondWithDomainError(&w, err)
+			return
+		}
+	}
+
+	// links are not saved for the shared anonymous user, the visitor gets a guest who owns them
+	if isSharedAnon(user) {
+		if user, err = controller.userRepository.CreateGuestWithContext(r.Context()); err != nil {
+			utils.RespondWithDomainError(&w, err)
+			return
+		}
+
+		link.UserID = user.ID
+		http.SetCookie(w, models.NewGuestCookie(*user))
+	}
+
+	// links of the workspaces are counted in the quota of their creators
+	usage, err := controller.quotaRepository.UsageWithContext(r.Context(), *user)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if !checkLinksQuota(w, usage) {
+		return
+	}
+
+	if link.Password != "" {
+		if link.Password, err = crypto.CreatePassword(link.Password); err != nil {
+			utils.RespondWithDomainError(&w, err)
+			return
+		}
+	}
+
 	linkRef, err := controller.linkRepository.CreateWithContext(r.Context(), link)
 
+	// quota is enforced by the repository, the check above could be passed by concurrent requests
+	if err == repository.ErrLinkQuotaExceeded || err == repository.ErrDailyLinkQuotaExceeded {
+		if current, usageErr := controller.quotaRepository.UsageWithContext(r.Context(), *user); usageErr == nil && !checkLinksQuota(w, current) {
+			return
+		}
+	}
+
 	if err != nil {
-		utils.RespondWithError(&w, http.StatusBadRequest, models.NewError(err.Error()))
+		utils.RespondWithDomainError(&w, err)
 		return
 	}
 
+	linkRef.CleanPrivateFields()
+	usage.AddLink()
+	setQuotaHeaders(w, usage.Links, usage.LinksToday)
+
 	utils.RespondWithJSON(&w, http.StatusCreated, linkRef)
 }
+
+// findMember returns membership of the user from context in the workspace.
+// ErrInsufficientMemberRole is returned when the member does not have the role.
+func (controller *LinkController) findMember(r *http.Request, workspaceID string, role string) (*models.WorkspaceMember, error) {
+	user, err := models.NewUserFromContext(r.Context())
+
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := controller.workspaceRepository.FindMemberWithContext(r.Context(), workspaceID, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !member.HasRole(role) {
+		return nil, repository.ErrInsufficientMemberRole
+	}
+
+	return member, nil
+}
+
+// findUserLink returns link by id from the route when it belongs to the user from context.
+// Links of the workspaces are available to the members with the role (or a higher one).
+// Links of other users and workspaces are reported as not found.
+func (controller *LinkController) findUserLink(r *http.Request, role string) (*models.Link, error) {
+	user, err := models.NewUserFromContext(r.Context())
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := mux.Vars(r)["id"]
+
+	if !ok {
+		return nil, repository.NewValidationError(models.FieldError{Field: "id", Code: "required", Message: "Id is not provided"})
+	}
+
+	link, err := controller.linkRepository.FindByIDWithContext(r.Context(), models.Link{ID: id})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if link.WorkspaceID == "" {
+		if link.UserID != user.ID || isSharedAnon(user) {
+			return nil, repository.ErrLinkNotFound
+		}
+
+		return link, nil
+	}
+
+	// owner of the link is not checked, members who left the workspace lose access to their links
+	if _, err = controller.findMember(r, link.WorkspaceID, role); err == repository.ErrWorkspaceNotFound {
+		return nil, repository.ErrLinkNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// Update changes mutable fields of the link. Fields which are missing in the request are not changed.
+// New password replaces the old one, protection is removed by passing "protected": false.
+func (controller *LinkController) Update(w http.ResponseWriter, r *http.Request) {
+	link, err := controller.findUserLink(r, models.WorkspaceEditor)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	original := *link
+	link.CleanPrivateFields()
+
+	if err = link.Populate(r); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	link.RestoreImmutableFields(original)
+
+	// fields which are not present in the patch keep their values, so they are not validated again
+	if err = link.ValidateChanges(original); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if link.Password != "" {
+		if link.Password, err = crypto.CreatePassword(link.Password); err != nil {
+			utils.RespondWithDomainError(&w, err)
+			return
+		}
+	} else if link.Protected {
+		link.Password = original.Password
+	}
+
+	updatedLink, err := controller.linkRepository.UpdateWithContext(r.Context(), *link)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	updatedLink.CleanPrivateFields()
+
+	utils.RespondWithJSON(&w, http.StatusOK, updatedLink)
+}
+
+// Delete removes the link with all its usages
+func (controller *LinkController) Delete(w http.ResponseWriter, r *http.Request) {
+	link, err := controller.findUserLink(r, models.WorkspaceEditor)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	err = controller.linkRepository.DeleteWithContext(r.Context(), *link)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Stats returns statistics of the link usages. Only owner of the link (or members of its workspace) have access to it
+func (controller *LinkController) Stats(w http.ResponseWriter, r *http.Request) {
+	link, err := controller.findUserLink(r, models.WorkspaceViewer)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	query, err := models.NewStatsQueryFromRequest(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	stats, err := controller.usageRepository.StatsByLinkWithContext(r.Context(), link.ID, query)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, stats)
+}
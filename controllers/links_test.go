@@ -9,7 +9,9 @@ import (
 	"shortener/controllers"
 	"shortener/models"
 	"shortener/models/options"
+	"shortener/repository"
 	testutils "shortener/testUtils"
+	"shortener/tracking"
 	"sort"
 	"testing"
 	"time"
@@ -40,18 +42,31 @@ func TestLinkFlows(t *testing.T) {
 		require.Equal(t, int64(1), count)
 	}()
 
-	controller := controllers.NewLinkController(suite.GetDB())
+	usageWriter := tracking.NewWriter(repository.NewUsageRepository(suite.GetDB()), tracking.Config{
+		QueueSize:     100,
+		Workers:       1,
+		BatchSize:     10,
+		FlushInterval: 100 * time.Millisecond,
+		Policy:        tracking.BlockPolicy,
+	})
+	linkRepository := repository.NewCachedLinkRepository(repository.NewSQLLinkRepository(suite.GetDB()))
+	controller := controllers.NewLinkController(suite.GetDB(), linkRepository, usageWriter)
 
 	user := AcquireUser(suite)
 
 	links := CreateLinks(controller, t, user)
 	FetchLinks(controller, t, user, links)
 	FetchLinksByIds(controller, t, links)
+
+	// drain the queue to update usages count
+	require.Nil(t, usageWriter.Close(context.Background()))
+
 	FetchLinks(controller, t, user, links)
+	UpdateAndDeleteLink(controller, t, user)
 }
 
 func AcquireUser(suite testutils.PostgresSuite) models.User {
-	controller := controllers.NewUserController(suite.GetDB())
+	controller := controllers.NewUserController(suite.GetDB(), repository.NewSQLLinkRepository(suite.GetDB()))
 
 	prepare := func() (*httptest.ResponseRecorder, *http.Request) {
 		json, _ := json.Marshal(controllers.UserRequest{user, "superman"})
@@ -198,7 +213,66 @@ func FetchLinksByIds(controller controllers.LinkController, t *testing.T, links
 			test.link.UsagesCount++
 		})
 	}
+}
+
+func UpdateAndDeleteLink(controller controllers.LinkController, t *testing.T, user models.User) {
+	stranger := models.User{ID: "00000000-0000-0000-0000-000000000000"}
+	links := CreateLinks(controller, t, user)
+	link := links[0]
+
+	prepare := func(method string, body string, owner *models.User) (*httptest.ResponseRecorder, *http.Request) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/test", bytes.NewBufferString(body))
+		r = r.WithContext(context.WithValue(r.Context(), "user", owner))
+		r = mux.SetURLVars(r, map[string]string{
+			"id": link.Code,
+		})
+
+		return w, r
+	}
+
+	t.Run("should not update link of another user", func(t *testing.T) {
+		w, r := prepare(http.MethodPatch, `{"url": "https://example.org"}`, &stranger)
+		controller.Update(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("should update link url", func(t *testing.T) {
+		w, r := prepare(http.MethodPatch, `{"url": "https://example.org", "id": "changed"}`, &user)
+		controller.Update(w, r)
 
-	// Sleep for one second to update usages count
-	time.Sleep(time.Second)
+		response := w.Result()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+
+		updatedLink := new(models.Link)
+		json.NewDecoder(response.Body).Decode(&updatedLink)
+
+		assert.Equal(t, "https://example.org", updatedLink.URL)
+		assert.Equal(t, link.ID, updatedLink.ID, "id should not be changed")
+		assert.Equal(t, link.Code, updatedLink.Code, "code should not be changed")
+	})
+
+	t.Run("should not delete link of another user", func(t *testing.T) {
+		w, r := prepare(http.MethodDelete, "", &stranger)
+		controller.Delete(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	for _, link := range links {
+		link := link
+
+		t.Run("should delete link "+link.ID, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodDelete, "/test", nil)
+			r = r.WithContext(context.WithValue(r.Context(), "user", &user))
+			r = mux.SetURLVars(r, map[string]string{
+				"id": link.ID,
+			})
+			controller.Delete(w, r)
+
+			assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+		})
+	}
 }
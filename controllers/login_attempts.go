@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"net/http"
+	"shortener/configuration"
+	"shortener/limiter"
+	"shortener/models"
+	"shortener/models/crypto"
+	"shortener/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginLockedCode is returned (with 429 status and Retry-After header) when the login is
+// temporarily locked after too many failed attempts. It is returned regardless of whether
+// the login exists, so it does not reveal registered logins.
+const LoginLockedCode = "account_locked"
+
+// TooManyAttemptsCode is returned (with 429 status and Retry-After header) when the next login
+// attempt is delayed after failures or the client IP has too many failed attempts.
+const TooManyAttemptsCode = "too_many_attempts"
+
+// loginAttempts and ipAttempts are shared by all user controllers, otherwise limits depend on the router
+var loginAttempts = newLoginLimiter(
+	configuration.GetConfiguration().LoginFreeAttempts,
+	configuration.GetConfiguration().LoginLockoutAttempts,
+)
+
+var ipAttempts = newLoginLimiter(
+	configuration.GetConfiguration().LoginIPFreeAttempts,
+	configuration.GetConfiguration().LoginIPLockoutAttempts,
+)
+
+// dummyPassword is compared with passwords of unknown logins, so they take the same time as known ones
+var dummyPassword string
+var dummyPasswordOnce sync.Once
+
+func newLoginLimiter(freeAttempts int, lockoutAttempts int) *limiter.BackoffLimiter {
+	config := configuration.GetConfiguration()
+
+	return limiter.NewBackoffLimiter(limiter.BackoffConfig{
+		FreeAttempts:    freeAttempts,
+		BaseDelay:       time.Duration(config.LoginBaseDelay) * time.Second,
+		MaxDelay:        time.Duration(config.LoginMaxDelay) * time.Second,
+		LockoutAttempts: lockoutAttempts,
+		LockoutDuration: time.Duration(config.LoginLockoutDuration) * time.Second,
+	})
+}
+
+// loginKey normalizes login, so attempts can not be spread across differently cased logins
+func loginKey(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+// checkLoginAttempts reserves attempt for both login and client IP. It responds with 429 and returns false
+// when the login attempt is not allowed. Reserved attempt is counted as a failure until it is released,
+// so concurrent guesses could not pass the check together before the password is compared.
+func checkLoginAttempts(w http.ResponseWriter, r *http.Request, login string) bool {
+	ipWait, _ := ipAttempts.Attempt(utils.ClientIP(r))
+	wait, locked := time.Duration(0), false
+
+	if ipWait == 0 {
+		if wait, locked = loginAttempts.Attempt(loginKey(login)); wait > 0 {
+			ipAttempts.Release(utils.ClientIP(r))
+		}
+	}
+
+	if wait == 0 && ipWait == 0 {
+		return true
+	}
+
+	if ipWait > wait {
+		wait = ipWait
+	}
+
+	respondTooManyAttempts(w, wait, locked)
+
+	return false
+}
+
+// checkPasswordAttempts reserves attempt to confirm password of the signed in user (e.g. before the account is deleted).
+// Attempts are keyed by the user id in the login limiter, so a stolen token could not be used to guess the password
+// without throttling. It responds with 429 and returns false when the attempt is not allowed.
+func checkPasswordAttempts(w http.ResponseWriter, userID string) bool {
+	wait, locked := loginAttempts.Attempt(passwordKey(userID))
+
+	if wait == 0 {
+		return true
+	}
+
+	respondTooManyAttempts(w, wait, locked)
+
+	return false
+}
+
+// resetPasswordAttempts forgets failed attempts of the user after the password is confirmed
+func resetPasswordAttempts(userID string) {
+	loginAttempts.Reset(passwordKey(userID))
+}
+
+// passwordKey is the limiter key of the password confirmations, it does not clash with the logins
+func passwordKey(userID string) string {
+	return "user:" + userID
+}
+
+// respondTooManyAttempts responds with 429 and Retry-After header
+func respondTooManyAttempts(w http.ResponseWriter, wait time.Duration, locked bool) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+
+	if locked {
+		utils.RespondWithError(&w, http.StatusTooManyRequests, models.NewErrorWithCode(LoginLockedCode, "Login is temporarily locked, please try again later"))
+	} else {
+		utils.RespondWithError(&w, http.StatusTooManyRequests, models.NewErrorWithCode(TooManyAttemptsCode, "Too many attempts, please try again later"))
+	}
+}
+
+// releaseLoginAttempt releases attempt which is not a guess (e.g. database error) for both login and client IP
+func releaseLoginAttempt(r *http.Request, login string) {
+	loginAttempts.Release(loginKey(login))
+	ipAttempts.Release(utils.ClientIP(r))
+}
+
+// resetLoginAttempts forgets failed attempts of the login after successful authorization,
+// attempt of the client IP is released
+func resetLoginAttempts(r *http.Request, login string) {
+	loginAttempts.Reset(loginKey(login))
+	ipAttempts.Release(utils.ClientIP(r))
+}
+
+// validateLoginPassword compares password with the user password or with the dummy one when user is unknown
+func validateLoginPassword(plainTextPassword string, user *models.User) bool {
+	if user == nil {
+		dummyPasswordOnce.Do(func() {
+			dummyPassword, _ = crypto.CreatePassword("dummy password")
+		})
+		crypto.ValidatePassword(plainTextPassword, dummyPassword)
+
+		return false
+	}
+
+	return crypto.ValidatePassword(plainTextPassword, user.Password)
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shortener/limiter"
+	"shortener/models"
+	"shortener/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeLoginUsers finds users by login or id in memory, other methods are not used by the login throttling
+type fakeLoginUsers struct {
+	repository.UserRepositoryInterface
+	users map[string]*models.User
+}
+
+func (users *fakeLoginUsers) FindByLoginWithContext(ctx context.Context, login string) (*models.User, error) {
+	if user, ok := users.users[login]; ok {
+		found := *user
+		return &found, nil
+	}
+
+	return nil, repository.ErrUserNotFound
+}
+
+func (users *fakeLoginUsers) FindCredentialsWithContext(ctx context.Context, ID string) (*models.User, error) {
+	for _, user := range users.users {
+		if user.ID == ID {
+			found := *user
+			return &found, nil
+		}
+	}
+
+	return nil, repository.ErrUserNotFound
+}
+
+// withLoginLimiters replaces shared limiters for the test
+func withLoginLimiters(t *testing.T, config limiter.BackoffConfig) {
+	login, ip := loginAttempts, ipAttempts
+	loginAttempts = limiter.NewBackoffLimiter(config)
+	ipAttempts = limiter.NewBackoffLimiter(limiter.BackoffConfig{FreeAttempts: 1000, LockoutDuration: time.Minute})
+
+	t.Cleanup(func() {
+		loginAttempts, ipAttempts = login, ip
+	})
+}
+
+func newLoginController(t *testing.T) UserController {
+	// minimal cost keeps the test fast, unknown logins are still compared with the dummy password
+	password, err := bcrypt.GenerateFromPassword([]byte("superman"), bcrypt.MinCost)
+	require.Nil(t, err)
+
+	return UserController{userRepository: &fakeLoginUsers{users: map[string]*models.User{
+		"clark": {ID: "1", Login: "clark", Password: string(password)},
+	}}}
+}
+
+func login(controller UserController, login string, password string) (*httptest.ResponseRecorder, models.Error) {
+	var body models.Error
+
+	r := httptest.NewRequest("POST", "/users/token", strings.NewReader(`{"login":"`+login+`","password":"`+password+`"}`))
+	w := httptest.NewRecorder()
+	controller.Authorize(w, r)
+	json.Unmarshal(w.Body.Bytes(), &body)
+
+	return w, body
+}
+
+func TestAuthorizeDoesNotRevealLogins(t *testing.T) {
+	withLoginLimiters(t, limiter.BackoffConfig{FreeAttempts: 10, LockoutDuration: time.Minute})
+	controller := newLoginController(t)
+
+	unknown, unknownBody := login(controller, "bruce", "superman")
+	wrong, wrongBody := login(controller, "clark", "batman")
+
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, unknown.Code, wrong.Code, "unknown login and wrong password should not be distinguished")
+	assert.Equal(t, unknownBody.Code, wrongBody.Code)
+	assert.Equal(t, unknownBody.Message, wrongBody.Message)
+}
+
+func TestAuthorizeDelaysFailedAttempts(t *testing.T) {
+	withLoginLimiters(t, limiter.BackoffConfig{
+		FreeAttempts:    2,
+		BaseDelay:       time.Minute,
+		MaxDelay:        time.Minute,
+		LockoutDuration: time.Hour,
+	})
+	controller := newLoginController(t)
+
+	login(controller, "clark", "batman")
+	login(controller, "clark", "batman")
+	w, _ := login(controller, "clark", "batman")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "attempt after free attempts should be compared")
+
+	w, body := login(controller, "clark", "superman")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "attempt should be delayed even with the correct password")
+	assert.Equal(t, TooManyAttemptsCode, body.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w, body = login(controller, "bruce", "batman")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "other logins should not be delayed")
+}
+
+func TestAuthorizeLocksLogin(t *testing.T) {
+	withLoginLimiters(t, limiter.BackoffConfig{
+		FreeAttempts:    10,
+		LockoutAttempts: 2,
+		LockoutDuration: time.Minute,
+	})
+	controller := newLoginController(t)
+
+	for _, name := range []string{"clark", "bruce"} {
+		login(controller, name, "batman")
+		login(controller, name, "batman")
+
+		w, body := login(controller, name, "superman")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, LoginLockedCode, body.Code, "known and unknown logins should be locked in the same way")
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	}
+}
+
+// confirmPassword calls the handler (DeleteMe or ChangePassword) as the signed in user with the body
+func confirmPassword(handler http.HandlerFunc, body string) (*httptest.ResponseRecorder, models.Error) {
+	var response models.Error
+
+	r := httptest.NewRequest("DELETE", "/users/me", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), "user", &models.User{ID: "1", Login: "clark", Role: models.RoleUser}))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	return w, response
+}
+
+func TestDeleteMeLocksPasswordConfirmation(t *testing.T) {
+	withLoginLimiters(t, limiter.BackoffConfig{
+		FreeAttempts:    10,
+		LockoutAttempts: 2,
+		LockoutDuration: time.Minute,
+	})
+	controller := newLoginController(t)
+
+	confirmPassword(controller.DeleteMe, `{"password":"batman"}`)
+	w, body := confirmPassword(controller.DeleteMe, `{"password":"batman"}`)
+	assert.Equal(t, repository.ErrInvalidPassword.Code, body.Code)
+
+	w, body = confirmPassword(controller.DeleteMe, `{"password":"superman"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "account should not be deleted after too many failed confirmations")
+	assert.Equal(t, LoginLockedCode, body.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestChangePasswordLocksPasswordConfirmation(t *testing.T) {
+	withLoginLimiters(t, limiter.BackoffConfig{
+		FreeAttempts:    10,
+		LockoutAttempts: 2,
+		LockoutDuration: time.Minute,
+	})
+	controller := newLoginController(t)
+
+	confirmPassword(controller.ChangePassword, `{"oldPassword":"batman","newPassword":"superman2"}`)
+	w, body := confirmPassword(controller.ChangePassword, `{"oldPassword":"batman","newPassword":"superman2"}`)
+	assert.Equal(t, repository.ErrInvalidPassword.Code, body.Code)
+
+	w, body = confirmPassword(controller.ChangePassword, `{"oldPassword":"superman","newPassword":"superman2"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "password should not be changed after too many failed confirmations")
+	assert.Equal(t, LoginLockedCode, body.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"net/http"
+	"shortener/models"
+	"shortener/utils"
+	"strconv"
+	"time"
+)
+
+// Headers with the most restrictive links quota of the user, reset is a unix time
+const (
+	QuotaLimitHeader     = "X-Quota-Limit"
+	QuotaRemainingHeader = "X-Quota-Remaining"
+	QuotaResetHeader     = "X-Quota-Reset"
+)
+
+// setQuotaHeaders reports the counter with the least remaining quota. Headers are not set when there are no limits
+func setQuotaHeaders(w http.ResponseWriter, counters ...models.QuotaCounter) {
+	var tightest *models.QuotaCounter
+
+	for i := range counters {
+		if counters[i].Remaining == nil {
+			continue
+		}
+
+		if tightest == nil || *counters[i].Remaining < *tightest.Remaining {
+			tightest = &counters[i]
+		}
+	}
+
+	if tightest == nil {
+		return
+	}
+
+	w.Header().Set(QuotaLimitHeader, strconv.FormatInt(tightest.Limit, 10))
+	w.Header().Set(QuotaRemainingHeader, strconv.FormatInt(*tightest.Remaining, 10))
+
+	if tightest.ResetsAt != nil {
+		w.Header().Set(QuotaResetHeader, strconv.FormatInt(tightest.ResetsAt.Unix(), 10))
+	}
+}
+
+// checkLinksQuota responds with an error and returns false when the user could not create more links.
+// Total links quota is not reset, so 403 is returned. Daily quota is reset, so 429 is returned with Retry-After.
+func checkLinksQuota(w http.ResponseWriter, usage *models.QuotaUsage) bool {
+	setQuotaHeaders(w, usage.Links, usage.LinksToday)
+
+	if usage.Links.IsExceeded() {
+		utils.RespondWithError(&w, http.StatusForbidden, models.NewErrorWithCode("link_quota_exceeded", "Links quota of the plan is exceeded"))
+		return false
+	}
+
+	if usage.LinksToday.IsExceeded() {
+		if usage.LinksToday.ResetsAt != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(*usage.LinksToday.ResetsAt).Seconds())+1))
+		}
+
+		utils.RespondWithError(&w, http.StatusTooManyRequests, models.NewErrorWithCode("daily_link_quota_exceeded", "Daily links quota is exceeded, please try again later"))
+		return false
+	}
+
+	return true
+}
@@ -3,16 +3,29 @@ package controllers
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"net/http"
+	"shortener/configuration"
+	"shortener/logger"
+	"shortener/metrics"
 	"shortener/models"
 	"shortener/models/crypto"
+	"shortener/models/options"
+	"shortener/notifier"
 	"shortener/repository"
 	"shortener/utils"
+	"strconv"
+	"time"
 )
 
 // UserController struct represents user controller
 type UserController struct {
-	userRepository repository.UserRepositoryInterface
+	userRepository          repository.UserRepositoryInterface
+	tokenRepository         repository.TokenRepositoryInterface
+	passwordResetRepository repository.PasswordResetRepositoryInterface
+	quotaRepository         repository.QuotaRepositoryInterface
+	linkRepository          repository.LinksRepositoryInterface
+	notifier                notifier.Notifier
 }
 
 // UserRequest represents object of user request
@@ -21,10 +34,60 @@ type UserRequest struct {
 	Password string `json:"password"`
 }
 
-// NewUserController func returns UserController object
-func NewUserController(db *sql.DB) UserController {
+// ProfileRequest represents body of the profile update
+type ProfileRequest struct {
+	Login string `json:"login"`
+}
+
+// PasswordConfirmation represents body of the sensitive actions which require password
+type PasswordConfirmation struct {
+	Password string `json:"password"`
+}
+
+// PasswordChangeRequest represents body of the password change
+type PasswordChangeRequest struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+// PasswordResetRequest represents body of the password reset request
+type PasswordResetRequest struct {
+	Login string `json:"login"`
+}
+
+// PasswordResetConfirmation represents body of the password reset with the token from the notification
+type PasswordResetConfirmation struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest represents body of refresh and logout requests
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// passwordResetTimeout limits creation and delivery of the password reset token
+const passwordResetTimeout = 30 * time.Second
+
+// NewUserController func returns UserController object. Links repository is passed explicitly,
+// so its cache is invalidated when links change the owner. Notifier is created using configuration,
+// password reset is unavailable when it is not configured
+func NewUserController(db *sql.DB, linkRepository repository.LinksRepositoryInterface) UserController {
+	userNotifier, err := notifier.NewNotifierFromConfiguration()
+
+	if err == notifier.ErrNotConfigured {
+		logger.Log.Warn("notifier is not configured, password reset is unavailable")
+	} else if err != nil {
+		logger.Log.Panic(err)
+	}
+
 	controller := UserController{
-		userRepository: repository.NewUserRepository(db),
+		userRepository:          repository.NewUserRepository(db),
+		tokenRepository:         repository.NewTokenRepository(db),
+		passwordResetRepository: repository.NewPasswordResetRepository(db),
+		quotaRepository:         repository.NewQuotaRepository(db),
+		linkRepository:          linkRepository,
+		notifier:                userNotifier,
 	}
 
 	return controller
@@ -36,7 +99,12 @@ func (controller *UserController) Create(w http.ResponseWriter, r *http.Request)
 	user, err := models.NewUserFromRequest(r)
 
 	if err != nil {
-		utils.RespondWithError(&w, http.StatusBadRequest, models.NewError(err.Error()))
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = models.ValidateNewPassword(user.Password); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
 		return
 	}
 
@@ -46,13 +114,20 @@ func (controller *UserController) Create(w http.ResponseWriter, r *http.Request)
 
 	user.Password, err = crypto.CreatePassword(user.Password)
 
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
 	createdUser, err := controller.userRepository.CreateWithContext(ctx, user)
 
 	if err != nil {
-		utils.RespondWithError(&w, http.StatusBadRequest, models.NewError(err.Error()))
+		utils.RespondWithDomainError(&w, err)
 		return
 	}
 
+	controller.claimGuestLinks(w, r, *createdUser)
+
 	utils.RespondWithJSON(&w, http.StatusCreated, *createdUser)
 }
 
@@ -62,27 +137,487 @@ func (controller *UserController) Authorize(w http.ResponseWriter, r *http.Reque
 	plainTextPassword := user.Password
 
 	if err != nil {
-		utils.RespondWithError(&w, http.StatusBadRequest, models.NewError(err.Error()))
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if !checkLoginAttempts(w, r, user.Login) {
+		metrics.Logins.Inc("throttled")
 		return
 	}
 
 	foundUser, err := controller.userRepository.FindByLoginWithContext(r.Context(), user.Login)
 
+	if err != nil && repository.KindOf(err) != repository.NotFound {
+		releaseLoginAttempt(r, user.Login)
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
 	if err != nil {
-		utils.RespondWithError(&w, http.StatusBadRequest, models.NewError(err.Error()))
+		foundUser = nil
+	}
+
+	// unknown login and wrong password are not distinguished
+	if !validateLoginPassword(plainTextPassword, foundUser) {
+		metrics.Logins.Inc("failure")
+		utils.RespondWithError(&w, http.StatusUnauthorized, models.NewErrorWithCode("invalid_credentials", "User is not authorized"))
 		return
 	}
 
-	ok := crypto.ValidatePassword(plainTextPassword, foundUser.Password)
+	resetLoginAttempts(r, user.Login)
 
-	if !ok {
-		utils.RespondWithError(&w, http.StatusUnauthorized, models.Error{"User is not authorized"})
+	// password is checked first, so disabled accounts are not revealed to the guessers
+	if foundUser.IsDisabled() {
+		metrics.Logins.Inc("failure")
+		utils.RespondWithDomainError(&w, repository.ErrAccountDisabled)
 		return
 	}
 
+	metrics.Logins.Inc("success")
 	foundUser.CleanPrivateFields()
+	controller.claimGuestLinks(w, r, *foundUser)
 
-	token, err := models.GenerateAuthToken(*foundUser)
+	plainRefreshToken, refreshToken, err := models.NewRefreshToken(foundUser.ID)
+
+	if err == nil {
+		_, err = controller.tokenRepository.CreateWithContext(r.Context(), refreshToken)
+	}
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	controller.respondWithTokens(w, *foundUser, plainRefreshToken)
+}
+
+// claimGuestLinks transfers links of the guest from the context to the user and removes guest cookie.
+// Failure is logged, so it does not fail signup or login.
+func (controller *UserController) claimGuestLinks(w http.ResponseWriter, r *http.Request, user models.User) {
+	guest, err := models.NewUserFromContext(r.Context())
+
+	if err != nil || !guest.IsGuest() {
+		return
+	}
+
+	claimed, err := controller.userRepository.ClaimGuestWithContext(r.Context(), guest.ID, user.ID)
+
+	if err != nil {
+		logger.FromContext(r.Context()).WithError(err).Error("unable to claim links of the guest")
+		return
+	}
+
+	repository.InvalidateLinks(controller.linkRepository, claimed)
+
+	http.SetCookie(w, models.ExpiredGuestCookie())
+}
+
+// respondWithTokens sends new access token together with the refresh token
+func (controller *UserController) respondWithTokens(w http.ResponseWriter, user models.User, plainRefreshToken string) {
+	token, err := models.GenerateAuthToken(user)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	token.RefreshToken = plainRefreshToken
 
 	utils.RespondWithJSON(&w, http.StatusOK, token)
 }
+
+// Refresh exchanges refresh token for the new pair of tokens. Refresh token could be used once,
+// all tokens of the session are revoked when it is presented again.
+func (controller *UserController) Refresh(w http.ResponseWriter, r *http.Request) {
+	var request RefreshRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	plainRefreshToken, next, err := models.NewRefreshToken("")
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	rotated, err := controller.tokenRepository.RotateWithContext(r.Context(), models.HashRefreshToken(request.RefreshToken), next)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	user, err := controller.userRepository.FindByIDWithContext(r.Context(), rotated.UserID, options.Options{})
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	// refresh tokens are revoked when the user is disabled, this check covers a concurrent rotation
+	if user.IsDisabled() {
+		utils.RespondWithDomainError(&w, repository.ErrAccountDisabled)
+		return
+	}
+
+	controller.respondWithTokens(w, *user, plainRefreshToken)
+}
+
+// Logout revokes access token of the request. Refresh token from the body is revoked with its whole session.
+func (controller *UserController) Logout(w http.ResponseWriter, r *http.Request) {
+	var request RefreshRequest
+	user, err := models.NewUserFromContext(r.Context())
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	// requests authorized by API keys do not have claims, keys are revoked separately
+	claims, err := models.NewClaimsFromContext(r.Context())
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, repository.ErrForbidden)
+		return
+	}
+
+	// body is optional, only the access token is revoked without it
+	if r.ContentLength != 0 {
+		if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+			return
+		}
+	}
+
+	if request.RefreshToken != "" {
+		err = controller.tokenRepository.RevokeFamilyWithContext(r.Context(), models.HashRefreshToken(request.RefreshToken), user.ID)
+
+		if err != nil {
+			utils.RespondWithDomainError(&w, err)
+			return
+		}
+	}
+
+	if claims.TokenID != "" {
+		if err = controller.tokenRepository.RevokeAccessTokenWithContext(r.Context(), claims.TokenID, claims.ExpiresAt); err != nil {
+			utils.RespondWithDomainError(&w, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isSharedAnon reports whether user is the shared anonymous user, which is used by the visitors without guest cookie
+func isSharedAnon(user *models.User) bool {
+	return user.Login == configuration.GetConfiguration().AnonUserLogin
+}
+
+// registeredUser returns user from the context. Anonymous user and guests do not have an account
+func registeredUser(r *http.Request) (*models.User, error) {
+	user, err := models.NewUserFromContext(r.Context())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isSharedAnon(user) || user.IsGuest() {
+		return nil, repository.ErrForbidden
+	}
+
+	return user, nil
+}
+
+// accountOwner returns user who is allowed to manage the account (e.g. API keys and profile).
+// Requests which are authorized by API keys are not allowed to do it.
+func accountOwner(r *http.Request) (*models.User, error) {
+	user, err := registeredUser(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if models.NewAPIKeyFromContext(r.Context()) != nil {
+		return nil, repository.ErrForbidden
+	}
+
+	return user, nil
+}
+
+// Me returns profile of the current user with links count and a page of links (limit and offset are used)
+func (controller *UserController) Me(w http.ResponseWriter, r *http.Request) {
+	user, err := registeredUser(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	opts := options.NewOptionsFromContext(r.Context())
+	profile, err := controller.userRepository.FindByIDWithContext(r.Context(), user.ID, *opts)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, profile)
+}
+
+// Usage returns current consumption of the quota by the current user
+func (controller *UserController) Usage(w http.ResponseWriter, r *http.Request) {
+	user, err := registeredUser(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	usage, err := controller.quotaRepository.UsageWithContext(r.Context(), *user)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	setQuotaHeaders(w, usage.Links, usage.LinksToday)
+	utils.RespondWithJSON(&w, http.StatusOK, usage)
+}
+
+// UpdateMe changes login of the current user
+func (controller *UserController) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var request ProfileRequest
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = models.ValidateLogin(request.Login); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	updatedUser, err := controller.userRepository.UpdateWithContext(r.Context(), models.User{ID: user.ID, Login: request.Login})
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, updatedUser)
+}
+
+// DeleteMe removes account of the current user after password confirmation.
+// Links, usages, tokens and API keys are removed by the database cascades.
+// Failed confirmations are throttled by the login limiter.
+func (controller *UserController) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	var request PasswordConfirmation
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	credentials, err := controller.userRepository.FindCredentialsWithContext(r.Context(), user.ID)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if !checkPasswordAttempts(w, user.ID) {
+		return
+	}
+
+	if !crypto.ValidatePassword(request.Password, credentials.Password) {
+		utils.RespondWithDomainError(&w, repository.ErrInvalidPassword)
+		return
+	}
+
+	resetPasswordAttempts(user.ID)
+
+	deleted, err := controller.userRepository.DeleteWithContext(r.Context(), *credentials)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	repository.InvalidateLinks(controller.linkRepository, deleted)
+
+	// all tokens of the deleted user are rejected (see TokenRepository.IsAccessTokenRevoked),
+	// the token of the request is revoked explicitly as well. Account is deleted already, so failure is only logged
+	if claims, err := models.NewClaimsFromContext(r.Context()); err == nil && claims.TokenID != "" {
+		if err = controller.tokenRepository.RevokeAccessTokenWithContext(r.Context(), claims.TokenID, claims.ExpiresAt); err != nil {
+			logger.FromContext(r.Context()).WithError(err).Error("unable to revoke access token of the deleted user")
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePassword sets new password of the current user after the old one is confirmed.
+// All sessions of the user are invalidated, so user should login again.
+// Failed confirmations of the old password are throttled by the login limiter.
+func (controller *UserController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var request PasswordChangeRequest
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	credentials, err := controller.userRepository.FindCredentialsWithContext(r.Context(), user.ID)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if !checkPasswordAttempts(w, user.ID) {
+		return
+	}
+
+	if !crypto.ValidatePassword(request.OldPassword, credentials.Password) {
+		utils.RespondWithDomainError(&w, repository.ErrInvalidPassword)
+		return
+	}
+
+	resetPasswordAttempts(user.ID)
+
+	controller.setPassword(w, r, user.ID, request.NewPassword)
+}
+
+// setPassword validates, hashes and saves the password
+func (controller *UserController) setPassword(w http.ResponseWriter, r *http.Request, userID string, password string) {
+	if err := models.ValidateNewPassword(password); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	hash, err := crypto.CreatePassword(password)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = controller.userRepository.UpdatePasswordWithContext(r.Context(), models.User{ID: userID, Password: hash}); err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestPasswordReset sends reset token to the user. Response is sent before the login is looked up,
+// so neither the response nor its time could be used to check whether user exists.
+// Password reset is unavailable when notifier is not configured.
+func (controller *UserController) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var request PasswordResetRequest
+
+	if controller.notifier == nil {
+		utils.RespondWithError(&w, http.StatusServiceUnavailable, models.NewErrorWithCode("password_reset_unavailable", "Password reset is not available"))
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	// request context is cancelled after the response, request id is kept for the log
+	ctx, cancel := context.WithTimeout(logger.WithRequestID(context.Background(), logger.RequestID(r.Context())), passwordResetTimeout)
+
+	go func() {
+		defer cancel()
+
+		if err := controller.sendPasswordResetToken(ctx, request.Login); err != nil {
+			logger.FromContext(ctx).WithError(err).Error("unable to send password reset token")
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendPasswordResetToken creates reset token and sends it to the login. Nothing is sent to unknown logins,
+// anonymous user, guests and logins which are not email addresses.
+func (controller *UserController) sendPasswordResetToken(ctx context.Context, login string) error {
+	user, err := controller.userRepository.FindByLoginWithContext(ctx, login)
+
+	if err != nil && repository.KindOf(err) == repository.NotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if isSharedAnon(user) || user.IsGuest() || !models.IsEmailAddress(user.Login) {
+		return nil
+	}
+
+	plainToken, token, err := models.NewPasswordResetToken(user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = controller.passwordResetRepository.CreateWithContext(ctx, token); err != nil {
+		return err
+	}
+
+	minutes := strconv.Itoa(configuration.GetConfiguration().PasswordResetTTL / 60)
+
+	return controller.notifier.Notify(ctx, notifier.Message{
+		To:      user.Login,
+		Subject: "Password reset",
+		Body:    "Use this token to set a new password: " + plainToken + "\nIt expires in " + minutes + " minutes.",
+	})
+}
+
+// ResetPassword sets new password using the token from the notification. Token could be used once
+func (controller *UserController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var request PasswordResetConfirmation
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	// password is checked before the token is consumed, so the token is not wasted
+	if err := models.ValidateNewPassword(request.Password); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	token, err := controller.passwordResetRepository.ConsumeWithContext(r.Context(), models.HashPasswordResetToken(request.Token))
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	controller.setPassword(w, r, token.UserID, request.Password)
+}
@@ -2,10 +2,15 @@ package controllers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"shortener/configuration"
 	"shortener/controllers"
+	"shortener/models"
+	"shortener/repository"
 	testutils "shortener/testUtils"
 	"testing"
 
@@ -35,7 +40,7 @@ func TestUserFlows(t *testing.T) {
 		require.Equal(t, int64(2), count)
 	}()
 
-	controller := controllers.NewUserController(suite.GetDB())
+	controller := controllers.NewUserController(suite.GetDB(), repository.NewSQLLinkRepository(suite.GetDB()))
 
 	userRequests := CreateUser(controller, t)
 
@@ -52,7 +57,8 @@ func CreateUser(controller controllers.UserController, t *testing.T) []controlle
 		expectedResult userTestResult
 	}{
 		{users[0], "superman", "should create a user", userTestResult{201, false}},
-		{users[0], "batman", "should thrown an error because user exists", userTestResult{400, false}},
+		{users[0], "batmobile", "should thrown an error because user exists", userTestResult{409, false}},
+		{"test-user-3", "batman", "should throw an error because password is too short", userTestResult{400, false}},
 		{users[1], "spiderman", "should create one more user", userTestResult{201, false}},
 	}
 	var userRequests []controllers.UserRequest
@@ -111,7 +117,55 @@ func AuthorizeUser(controller controllers.UserController, t *testing.T, user con
 				tokenString, ok := token.(string)
 				require.True(t, ok)
 				require.NotEmpty(t, tokenString)
+				assert.NotEmpty(t, respv["refreshToken"], "refresh token should be issued")
 			}
 		})
 	}
 }
+
+func TestProfileIsForbiddenForAnonymousUser(t *testing.T) {
+	os.Setenv("ANON_USER_LOGIN", "anon")
+	configuration.Reload()
+
+	controller := controllers.NewUserController(nil, nil)
+	anon := &models.User{ID: "1", Login: "anon"}
+	key := &models.APIKey{Scopes: []string{models.ScopeLinksRead}}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		ctx     context.Context
+	}{
+		{"should not show profile of anonymous user", controller.Me, context.WithValue(context.Background(), "user", anon)},
+		{"should not change anonymous user", controller.UpdateMe, context.WithValue(context.Background(), "user", anon)},
+		{"should not delete account using API key", controller.DeleteMe, context.WithValue(
+			context.WithValue(context.Background(), "user", &models.User{ID: "2", Login: "batman"}), "apiKey", key,
+		)},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/users/me", nil).WithContext(test.ctx)
+
+			test.handler(w, r)
+
+			assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestPasswordResetIsUnavailableWithoutNotifier(t *testing.T) {
+	os.Unsetenv("NOTIFIER")
+	configuration.Reload()
+
+	controller := controllers.NewUserController(nil, nil)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/users/password/reset", bytes.NewBufferString(`{"login":"batman@example.com"}`))
+
+	controller.RequestPasswordReset(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Result().StatusCode, "reset tokens should not be created without notifier")
+}
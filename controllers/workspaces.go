@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"shortener/configuration"
+	"shortener/models"
+	"shortener/models/options"
+	"shortener/repository"
+	"shortener/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// WorkspaceController manages workspaces, their members and invitations
+type WorkspaceController struct {
+	linkRepository      repository.LinksRepositoryInterface
+	userRepository      repository.UserRepositoryInterface
+	workspaceRepository repository.WorkspaceRepositoryInterface
+}
+
+// InvitationRequest represents body of the invitation to the workspace
+type InvitationRequest struct {
+	Login string `json:"login"`
+	Role  string `json:"role"`
+}
+
+// MemberRequest represents body of the member role change
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+// NewWorkspaceController func returns WorkspaceController object
+func NewWorkspaceController(db *sql.DB, linkRepository repository.LinksRepositoryInterface) WorkspaceController {
+	return WorkspaceController{
+		linkRepository:      linkRepository,
+		userRepository:      repository.NewUserRepository(db),
+		workspaceRepository: repository.NewWorkspaceRepository(db),
+	}
+}
+
+// findMember returns membership of the user in the workspace from the route.
+// ErrInsufficientMemberRole is returned when the member does not have the role.
+func (controller *WorkspaceController) findMember(r *http.Request, user *models.User, role string) (*models.WorkspaceMember, error) {
+	member, err := controller.workspaceRepository.FindMemberWithContext(r.Context(), mux.Vars(r)["id"], user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !member.HasRole(role) {
+		return nil, repository.ErrInsufficientMemberRole
+	}
+
+	return member, nil
+}
+
+// Create saves new workspace, the user becomes its owner
+func (controller *WorkspaceController) Create(w http.ResponseWriter, r *http.Request) {
+	var workspace models.Workspace
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = workspace.Populate(r); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = workspace.Validate(); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	created, err := controller.workspaceRepository.CreateWithContext(r.Context(), workspace, user.ID)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusCreated, created)
+}
+
+// List returns workspaces of the user
+func (controller *WorkspaceController) List(w http.ResponseWriter, r *http.Request) {
+	user, err := registeredUser(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	workspaces, err := controller.workspaceRepository.FindAllByUserWithContext(r.Context(), *user)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, workspaces)
+}
+
+// FetchByID returns workspace with its members
+func (controller *WorkspaceController) FetchByID(w http.ResponseWriter, r *http.Request) {
+	user, err := registeredUser(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	member, err := controller.findMember(r, user, models.WorkspaceViewer)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	workspace, err := controller.workspaceRepository.FindByIDWithContext(r.Context(), member.WorkspaceID)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	workspace.Role = member.Role
+
+	utils.RespondWithJSON(&w, http.StatusOK, workspace)
+}
+
+// Delete removes the workspace with all its links. Only owners are allowed to do it
+func (controller *WorkspaceController) Delete(w http.ResponseWriter, r *http.Request) {
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	member, err := controller.findMember(r, user, models.WorkspaceOwner)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	deleted, err := controller.workspaceRepository.DeleteWithContext(r.Context(), models.Workspace{ID: member.WorkspaceID})
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	repository.InvalidateLinks(controller.linkRepository, deleted)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Links returns links of the workspace using offset and limit
+func (controller *WorkspaceController) Links(w http.ResponseWriter, r *http.Request) {
+	opts := options.NewOptionsFromContext(r.Context())
+	user, err := registeredUser(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	member, err := controller.findMember(r, user, models.WorkspaceViewer)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	links, err := controller.linkRepository.FindAllByWorkspaceWithContext(r.Context(), models.Workspace{ID: member.WorkspaceID}, *opts)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, links)
+}
+
+// Invite creates invitation of the registered user to the workspace. Only owners are allowed to do it.
+// Unknown logins get the same response as registered users, so invitations do not reveal registered logins.
+func (controller *WorkspaceController) Invite(w http.ResponseWriter, r *http.Request) {
+	var request InvitationRequest
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	member, err := controller.findMember(r, user, models.WorkspaceOwner)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = models.ValidateWorkspaceRole(request.Role); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	invited, err := controller.userRepository.FindByLoginWithContext(r.Context(), request.Login)
+
+	if err == nil && (invited.IsGuest() || invited.Login == configuration.GetConfiguration().AnonUserLogin) {
+		err = repository.ErrUserNotFound
+	}
+
+	if repository.KindOf(err) == repository.NotFound {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	invitation := models.NewWorkspaceInvitation(member.WorkspaceID, invited.ID, request.Role)
+	invitation.InvitedBy = user.ID
+
+	if _, err = controller.workspaceRepository.CreateInvitationWithContext(r.Context(), invitation); err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Invitations returns pending invitations of the current user
+func (controller *WorkspaceController) Invitations(w http.ResponseWriter, r *http.Request) {
+	user, err := registeredUser(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	invitations, err := controller.workspaceRepository.FindInvitationsByUserWithContext(r.Context(), *user)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, invitations)
+}
+
+// AcceptInvitation adds the current user to the workspace
+func (controller *WorkspaceController) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	member, err := controller.workspaceRepository.AcceptInvitationWithContext(r.Context(), mux.Vars(r)["id"], user.ID)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, member)
+}
+
+// DeclineInvitation removes invitation of the current user
+func (controller *WorkspaceController) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = controller.workspaceRepository.DeclineInvitationWithContext(r.Context(), mux.Vars(r)["id"], user.ID); err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateMember changes role of the member. Only owners are allowed to do it
+func (controller *WorkspaceController) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	var request MemberRequest
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	owner, err := controller.findMember(r, user, models.WorkspaceOwner)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	if err = models.ValidateWorkspaceRole(request.Role); err != nil {
+		utils.RespondWithDomainError(&w, repository.NewValidationError(err))
+		return
+	}
+
+	member, err := controller.workspaceRepository.UpdateMemberWithContext(r.Context(), models.WorkspaceMember{
+		WorkspaceID: owner.WorkspaceID,
+		UserID:      mux.Vars(r)["userId"],
+		Role:        request.Role,
+	})
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	utils.RespondWithJSON(&w, http.StatusOK, member)
+}
+
+// RemoveMember removes the member from the workspace. Owners remove any member, other members could only leave.
+// Links of the removed member stay in the workspace.
+func (controller *WorkspaceController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	user, err := accountOwner(r)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	memberID := mux.Vars(r)["userId"]
+	role := models.WorkspaceOwner
+
+	if memberID == user.ID {
+		role = models.WorkspaceViewer
+	}
+
+	member, err := controller.findMember(r, user, role)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	transferred, err := controller.workspaceRepository.RemoveMemberWithContext(r.Context(), member.WorkspaceID, memberID)
+
+	if err != nil {
+		utils.RespondWithDomainError(&w, err)
+		return
+	}
+
+	repository.InvalidateLinks(controller.linkRepository, transferred)
+
+	w.WriteHeader(http.StatusNoContent)
+}
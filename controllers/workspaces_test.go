@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"shortener/models"
+	"shortener/repository"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInvitations accepts invitations to any workspace where the user is an owner
+type fakeInvitations struct {
+	repository.WorkspaceRepositoryInterface
+	invited []string
+}
+
+func (workspaces *fakeInvitations) FindMemberWithContext(ctx context.Context, workspaceID string, userID string) (*models.WorkspaceMember, error) {
+	return &models.WorkspaceMember{WorkspaceID: workspaceID, UserID: userID, Role: models.WorkspaceOwner}, nil
+}
+
+func (workspaces *fakeInvitations) CreateInvitationWithContext(ctx context.Context, invitation models.WorkspaceInvitation) (*models.WorkspaceInvitation, error) {
+	workspaces.invited = append(workspaces.invited, invitation.UserID)
+	return &invitation, nil
+}
+
+// fakeLinks finds links by id or code
+type fakeLinks struct {
+	repository.LinksRepositoryInterface
+	links map[string]models.Link
+}
+
+func (fake *fakeLinks) FindByIDWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
+	for _, stored := range fake.links {
+		if stored.ID == link.ID || stored.Code == link.ID {
+			return &stored, nil
+		}
+	}
+
+	return &link, repository.ErrLinkNotFound
+}
+
+// fakeWorkspaceDeletion deletes workspaces together with their links
+type fakeWorkspaceDeletion struct {
+	repository.WorkspaceRepositoryInterface
+	links *fakeLinks
+}
+
+func (workspaces *fakeWorkspaceDeletion) FindMemberWithContext(ctx context.Context, workspaceID string, userID string) (*models.WorkspaceMember, error) {
+	return &models.WorkspaceMember{WorkspaceID: workspaceID, UserID: userID, Role: models.WorkspaceOwner}, nil
+}
+
+func (workspaces *fakeWorkspaceDeletion) DeleteWithContext(ctx context.Context, workspace models.Workspace) ([]models.Link, error) {
+	deleted := make([]models.Link, 0)
+
+	for id, link := range workspaces.links.links {
+		if link.WorkspaceID == workspace.ID {
+			deleted = append(deleted, models.Link{ID: link.ID, Code: link.Code})
+			delete(workspaces.links.links, id)
+		}
+	}
+
+	return deleted, nil
+}
+
+func TestDeleteEvictsWorkspaceLinksFromCache(t *testing.T) {
+	fake := &fakeLinks{links: map[string]models.Link{
+		"1": {ID: "1", Code: "abc", URL: "https://example.com", WorkspaceID: "1"},
+	}}
+	links := repository.NewCachedLinkRepository(fake)
+	controller := WorkspaceController{linkRepository: links, workspaceRepository: &fakeWorkspaceDeletion{links: fake}}
+
+	_, err := links.FindByID(models.Link{ID: "abc"})
+	require.Nil(t, err)
+
+	r := httptest.NewRequest("DELETE", "/workspaces/1", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+	r = r.WithContext(context.WithValue(r.Context(), "user", &models.User{ID: "owner", Login: "bruce", Role: models.RoleUser}))
+	w := httptest.NewRecorder()
+	controller.Delete(w, r)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err = links.FindByID(models.Link{ID: "abc"})
+	assert.Equal(t, repository.ErrLinkNotFound, err, "link of the deleted workspace should not be redirected")
+}
+
+func TestInviteDoesNotRevealLogins(t *testing.T) {
+	workspaces := &fakeInvitations{}
+	controller := WorkspaceController{
+		userRepository: &fakeLoginUsers{users: map[string]*models.User{
+			"clark": {ID: "1", Login: "clark"},
+			"guest": {ID: "2", Login: "guest", Role: models.RoleGuest},
+		}},
+		workspaceRepository: workspaces,
+	}
+	owner := &models.User{ID: "owner", Login: "bruce", Role: models.RoleUser}
+
+	invite := func(login string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/workspaces/1/invitations", strings.NewReader(`{"login":"`+login+`","role":"viewer"}`))
+		r = mux.SetURLVars(r, map[string]string{"id": "1"})
+		r = r.WithContext(context.WithValue(r.Context(), "user", owner))
+		w := httptest.NewRecorder()
+		controller.Invite(w, r)
+
+		return w
+	}
+
+	registered := invite("clark")
+	unknown := invite("lois")
+	guest := invite("guest")
+
+	assert.Equal(t, http.StatusAccepted, registered.Code)
+	assert.Equal(t, registered.Code, unknown.Code, "unknown login should get the same status")
+	assert.Equal(t, registered.Body.String(), unknown.Body.String(), "unknown login should get the same body")
+	assert.Equal(t, registered.Code, guest.Code, "guest should not be distinguishable from unknown login")
+	assert.Equal(t, []string{"1"}, workspaces.invited, "only registered user should be invited")
+}
@@ -0,0 +1,126 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter limits count of failed attempts per key (e.g. link id) within a time window
+type AttemptLimiter struct {
+	mutex       sync.Mutex
+	attempts    map[string]*attempts
+	maxAttempts int
+	window      time.Duration
+	lastCleanup time.Time
+}
+
+type attempts struct {
+	count int
+	start time.Time
+}
+
+// NewAttemptLimiter creates AttemptLimiter
+func NewAttemptLimiter(maxAttempts int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{
+		attempts:    make(map[string]*attempts),
+		maxAttempts: maxAttempts,
+		window:      window,
+	}
+}
+
+// Wait returns zero when next attempt is allowed for the key, otherwise it returns time to wait
+func (l *AttemptLimiter) Wait(key string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.wait(key, time.Now())
+}
+
+func (l *AttemptLimiter) wait(key string, now time.Time) time.Duration {
+	value, ok := l.attempts[key]
+
+	if !ok || value.count < l.maxAttempts {
+		return 0
+	}
+
+	wait := value.start.Add(l.window).Sub(now)
+
+	if wait <= 0 {
+		delete(l.attempts, key)
+		return 0
+	}
+
+	return wait
+}
+
+// Attempt reserves attempt for the key. When attempt is allowed zero is returned and attempt is counted
+// as a failure until it is released (or the key is reset), so concurrent attempts could not pass together.
+// Otherwise time to wait is returned and attempt is not counted.
+func (l *AttemptLimiter) Attempt(key string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+
+	if wait := l.wait(key, now); wait > 0 {
+		return wait
+	}
+
+	l.fail(key, now)
+
+	return 0
+}
+
+// Release forgets attempt reserved by Attempt which is not failed
+func (l *AttemptLimiter) Release(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if value, ok := l.attempts[key]; ok && value.count > 0 {
+		value.count--
+	}
+}
+
+// Fail registers failed attempt for the key
+func (l *AttemptLimiter) Fail(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.fail(key, time.Now())
+}
+
+func (l *AttemptLimiter) fail(key string, now time.Time) {
+	l.cleanup(now)
+
+	value, ok := l.attempts[key]
+
+	if !ok || !now.Before(value.start.Add(l.window)) {
+		value = &attempts{start: now}
+		l.attempts[key] = value
+	}
+
+	value.count++
+}
+
+// Reset forgets failed attempts for the key
+func (l *AttemptLimiter) Reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// cleanup removes expired windows, so memory is not leaked by keys which are not used anymore
+func (l *AttemptLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < l.window {
+		return
+	}
+
+	for key, value := range l.attempts {
+		if !now.Before(value.start.Add(l.window)) {
+			delete(l.attempts, key)
+		}
+	}
+
+	l.lastCleanup = now
+}
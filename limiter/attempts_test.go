@@ -0,0 +1,44 @@
+package limiter_test
+
+import (
+	"shortener/limiter"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	l := limiter.NewAttemptLimiter(2, 50*time.Millisecond)
+
+	assert.Zero(t, l.Wait("key"), "first attempt should be allowed")
+
+	l.Fail("key")
+	assert.Zero(t, l.Wait("key"), "attempt should be allowed until limit is reached")
+
+	l.Fail("key")
+	assert.True(t, l.Wait("key") > 0, "attempt should be rejected when limit is reached")
+	assert.Zero(t, l.Wait("another-key"), "keys should be limited independently")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Zero(t, l.Wait("key"), "attempt should be allowed after the window")
+
+	l.Fail("key")
+	l.Fail("key")
+	l.Reset("key")
+	assert.Zero(t, l.Wait("key"), "attempt should be allowed after reset")
+}
+
+func TestAttemptLimiterAttempt(t *testing.T) {
+	l := limiter.NewAttemptLimiter(2, time.Minute)
+
+	assert.Zero(t, l.Attempt("key"))
+	assert.Zero(t, l.Attempt("key"), "attempt should be allowed when previous one is not failed yet")
+	assert.True(t, l.Attempt("key") > 0, "reserved attempts should be counted as failures")
+
+	l.Release("key")
+	assert.Zero(t, l.Attempt("key"), "released attempts should not be counted")
+
+	l.Reset("key")
+	assert.Zero(t, l.Wait("key"), "attempt should be allowed after reset")
+}
@@ -0,0 +1,170 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// BackoffConfig describes how failed attempts are slowed down.
+// After FreeAttempts failures every next attempt is delayed by BaseDelay doubled for each failure
+// (up to MaxDelay). After LockoutAttempts failures the key is locked for LockoutDuration.
+// Failures are forgotten after LockoutDuration without new failures.
+type BackoffConfig struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAttempts int
+	LockoutDuration time.Duration
+}
+
+// BackoffLimiter delays attempts with exponential backoff and locks keys (e.g. logins) temporarily
+type BackoffLimiter struct {
+	mutex       sync.Mutex
+	failures    map[string]*failures
+	config      BackoffConfig
+	lastCleanup time.Time
+}
+
+type failures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// NewBackoffLimiter creates BackoffLimiter
+func NewBackoffLimiter(config BackoffConfig) *BackoffLimiter {
+	return &BackoffLimiter{
+		failures: make(map[string]*failures),
+		config:   config,
+	}
+}
+
+// Wait returns zero when next attempt is allowed for the key, otherwise it returns time to wait.
+// Locked is true when the key is locked rather than delayed.
+func (l *BackoffLimiter) Wait(key string) (wait time.Duration, locked bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.wait(key, time.Now())
+}
+
+func (l *BackoffLimiter) wait(key string, now time.Time) (wait time.Duration, locked bool) {
+	value, ok := l.failures[key]
+
+	if !ok {
+		return 0, false
+	}
+
+	if now.Before(value.lockedUntil) {
+		return value.lockedUntil.Sub(now), true
+	}
+
+	if wait = value.last.Add(l.delay(value.count)).Sub(now); wait > 0 {
+		return wait, false
+	}
+
+	return 0, false
+}
+
+// delay returns time between attempts after the count of failures
+func (l *BackoffLimiter) delay(count int) time.Duration {
+	extra := count - l.config.FreeAttempts
+
+	if extra <= 0 {
+		return 0
+	}
+
+	delay := l.config.BaseDelay
+
+	for i := 1; i < extra && delay < l.config.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > l.config.MaxDelay {
+		return l.config.MaxDelay
+	}
+
+	return delay
+}
+
+// Attempt reserves attempt for the key. When attempt is allowed zero is returned and attempt is counted
+// as a failure until it is released (or the key is reset), so concurrent attempts could not pass together.
+// Otherwise time to wait is returned and attempt is not counted.
+func (l *BackoffLimiter) Attempt(key string) (wait time.Duration, locked bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if wait, locked = l.wait(key, time.Now()); wait > 0 {
+		return wait, locked
+	}
+
+	l.fail(key)
+
+	return 0, false
+}
+
+// Release forgets attempt reserved by Attempt which is not failed. Lockout is not cancelled
+func (l *BackoffLimiter) Release(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if value, ok := l.failures[key]; ok && value.count > 0 {
+		value.count--
+	}
+}
+
+// Fail registers failed attempt for the key
+func (l *BackoffLimiter) Fail(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.fail(key)
+}
+
+func (l *BackoffLimiter) fail(key string) {
+	now := time.Now()
+	l.cleanup(now)
+
+	value, ok := l.failures[key]
+
+	if !ok || l.isForgotten(value, now) {
+		value = &failures{}
+		l.failures[key] = value
+	}
+
+	value.count++
+	value.last = now
+
+	if l.config.LockoutAttempts > 0 && value.count >= l.config.LockoutAttempts {
+		value.lockedUntil = now.Add(l.config.LockoutDuration)
+		// the key starts from scratch after the lockout
+		value.count = 0
+	}
+}
+
+// Reset forgets failed attempts for the key
+func (l *BackoffLimiter) Reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.failures, key)
+}
+
+func (l *BackoffLimiter) isForgotten(value *failures, now time.Time) bool {
+	return !now.Before(value.last.Add(l.config.LockoutDuration)) && !now.Before(value.lockedUntil)
+}
+
+// cleanup removes forgotten keys, so memory is not leaked by keys which are not used anymore
+func (l *BackoffLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < l.config.LockoutDuration {
+		return
+	}
+
+	for key, value := range l.failures {
+		if l.isForgotten(value, now) {
+			delete(l.failures, key)
+		}
+	}
+
+	l.lastCleanup = now
+}
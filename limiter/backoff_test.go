@@ -0,0 +1,98 @@
+package limiter_test
+
+import (
+	"shortener/limiter"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffLimiter(t *testing.T) {
+	l := limiter.NewBackoffLimiter(limiter.BackoffConfig{
+		FreeAttempts:    2,
+		BaseDelay:       20 * time.Millisecond,
+		MaxDelay:        40 * time.Millisecond,
+		LockoutAttempts: 5,
+		LockoutDuration: 100 * time.Millisecond,
+	})
+
+	l.Fail("key")
+	l.Fail("key")
+	wait, locked := l.Wait("key")
+	assert.Zero(t, wait, "free attempts should not be delayed")
+	assert.False(t, locked)
+
+	l.Fail("key")
+	wait, locked = l.Wait("key")
+	assert.True(t, wait > 0 && wait <= 20*time.Millisecond, "attempt should be delayed by base delay")
+	assert.False(t, locked)
+
+	l.Fail("key")
+	wait, _ = l.Wait("key")
+	assert.True(t, wait > 20*time.Millisecond && wait <= 40*time.Millisecond, "delay should be doubled")
+
+	wait, _ = l.Wait("another-key")
+	assert.Zero(t, wait, "keys should be limited independently")
+
+	l.Fail("key")
+	wait, locked = l.Wait("key")
+	assert.True(t, locked, "key should be locked after lockout attempts")
+	assert.True(t, wait > 40*time.Millisecond)
+
+	time.Sleep(110 * time.Millisecond)
+	wait, locked = l.Wait("key")
+	assert.Zero(t, wait, "key should be unlocked after lockout duration")
+	assert.False(t, locked)
+
+	l.Fail("key")
+	l.Fail("key")
+	l.Fail("key")
+	l.Reset("key")
+	wait, _ = l.Wait("key")
+	assert.Zero(t, wait, "attempt should be allowed after reset")
+}
+
+func TestBackoffLimiterAttempt(t *testing.T) {
+	l := limiter.NewBackoffLimiter(limiter.BackoffConfig{
+		FreeAttempts:    1,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Second,
+		LockoutAttempts: 3,
+		LockoutDuration: time.Minute,
+	})
+
+	wait, _ := l.Attempt("key")
+	assert.Zero(t, wait, "free attempt should be allowed")
+
+	wait, _ = l.Attempt("key")
+	assert.Zero(t, wait, "attempt after free attempts should be allowed when previous one is not failed yet")
+
+	wait, locked := l.Attempt("key")
+	assert.True(t, wait > 0, "reserved attempts should be counted as failures")
+	assert.False(t, locked)
+
+	l.Release("key")
+	l.Release("key")
+	wait, _ = l.Attempt("key")
+	assert.Zero(t, wait, "released attempts should not be counted")
+
+	allowed := make(chan bool, 10)
+
+	for i := 0; i < 10; i++ {
+		go func() {
+			wait, _ := l.Attempt("concurrent")
+			allowed <- wait == 0
+		}()
+	}
+
+	count := 0
+
+	for i := 0; i < 10; i++ {
+		if <-allowed {
+			count++
+		}
+	}
+
+	assert.Equal(t, 2, count, "concurrent attempts should not pass together")
+}
@@ -0,0 +1,108 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rate describes token bucket: Limit tokens are refilled evenly during Period, bucket holds at most Limit tokens
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Result of taking a token. Reset is a time until the bucket is full again,
+// RetryAfter is a time until the next token when the request is not allowed.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps state of the token buckets. MemoryStore keeps them in the process,
+// shared store (e.g. redis) could be implemented to share limits between instances.
+type Store interface {
+	Take(ctx context.Context, key string, rate Rate) (Result, error)
+}
+
+// MemoryStore keeps token buckets in memory of the process
+type MemoryStore struct {
+	mutex       sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// NewMemoryStore creates MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take removes a token from the bucket of the key, request is allowed when there was a token
+func (s *MemoryStore) Take(ctx context.Context, key string, rate Rate) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.cleanup(now)
+
+	value, ok := s.buckets[key]
+
+	if !ok {
+		value = &bucket{tokens: float64(rate.Limit), updated: now}
+		s.buckets[key] = value
+	}
+
+	value.period = rate.Period
+
+	return take(value, rate, now), nil
+}
+
+// take refills the bucket according to the elapsed time and takes a token from it
+func take(value *bucket, rate Rate, now time.Time) Result {
+	perToken := rate.Period / time.Duration(rate.Limit)
+	capacity := float64(rate.Limit)
+
+	value.tokens = math.Min(capacity, value.tokens+float64(now.Sub(value.updated))/float64(perToken))
+	value.updated = now
+
+	result := Result{Limit: rate.Limit}
+
+	if value.tokens >= 1 {
+		value.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - value.tokens) * float64(perToken))
+	}
+
+	result.Remaining = int(value.tokens)
+	result.Reset = time.Duration((capacity - value.tokens) * float64(perToken))
+
+	return result
+}
+
+// cleanup removes full buckets, so memory is not leaked by keys which are not used anymore
+func (s *MemoryStore) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < time.Minute {
+		return
+	}
+
+	for key, value := range s.buckets {
+		if now.Sub(value.updated) >= value.period {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.lastCleanup = now
+}
@@ -0,0 +1,40 @@
+package limiter_test
+
+import (
+	"context"
+	"shortener/limiter"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := limiter.NewMemoryStore()
+	rate := limiter.Rate{Limit: 2, Period: 100 * time.Millisecond}
+	ctx := context.Background()
+
+	result, err := store.Take(ctx, "key", rate)
+	require.Nil(t, err)
+	assert.True(t, result.Allowed, "first request should be allowed")
+	assert.Equal(t, 2, result.Limit)
+	assert.Equal(t, 1, result.Remaining)
+
+	result, _ = store.Take(ctx, "key", rate)
+	assert.True(t, result.Allowed, "request should be allowed until bucket is empty")
+	assert.Equal(t, 0, result.Remaining)
+
+	result, _ = store.Take(ctx, "key", rate)
+	assert.False(t, result.Allowed, "request should be rejected when bucket is empty")
+	assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= 50*time.Millisecond)
+	assert.True(t, result.Reset > 50*time.Millisecond)
+
+	result, _ = store.Take(ctx, "another-key", rate)
+	assert.True(t, result.Allowed, "keys should be limited independently")
+
+	time.Sleep(60 * time.Millisecond)
+
+	result, _ = store.Take(ctx, "key", rate)
+	assert.True(t, result.Allowed, "request should be allowed after the token is refilled")
+}
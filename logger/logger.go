@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"shortener/configuration"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted replaces values of the secret fields
+const Redacted = "[REDACTED]"
+
+// secretFields are parts of the field names which values should never be logged
+var secretFields = []string{"password", "token", "secret", "authorization", "apikey", "api_key", "cookie"}
+
+// Log is the application logger. Level and format are taken from LOG_LEVEL and LOG_FORMAT
+var Log = newLogger()
+
+func newLogger() *logrus.Logger {
+	config := configuration.GetConfiguration()
+	log := logrus.New()
+	log.Out = os.Stdout
+
+	var formatter logrus.Formatter = &logrus.JSONFormatter{}
+
+	if config.LogFormat == "text" {
+		formatter = &logrus.TextFormatter{}
+	}
+
+	log.Formatter = &redactingFormatter{formatter}
+
+	if level, err := logrus.ParseLevel(config.LogLevel); err == nil {
+		log.Level = level
+	} else {
+		log.WithField("level", config.LogLevel).Warn("unknown log level, info is used")
+	}
+
+	return log
+}
+
+// redactingFormatter hides values of the secret fields before they are formatted
+type redactingFormatter struct {
+	logrus.Formatter
+}
+
+// IsSecretField reports whether field value should be redacted
+func IsSecretField(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, secret := range secretFields {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	redacted := *entry
+	redacted.Data = make(logrus.Fields, len(entry.Data))
+
+	for key, value := range entry.Data {
+		if IsSecretField(key) {
+			value = Redacted
+		}
+
+		redacted.Data[key] = value
+	}
+
+	return f.Formatter.Format(&redacted)
+}
+
+type requestFieldsKey struct{}
+
+// requestFields are collected while request is handled and logged by the access log
+type requestFields struct {
+	mutex  sync.Mutex
+	fields logrus.Fields
+}
+
+// WithRequestFields returns context which collects fields of the access log
+func WithRequestFields(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestFieldsKey{}, &requestFields{fields: logrus.Fields{}})
+}
+
+// SetRequestField adds field to the access log of the request
+func SetRequestField(ctx context.Context, key string, value interface{}) {
+	holder, ok := ctx.Value(requestFieldsKey{}).(*requestFields)
+
+	if !ok {
+		return
+	}
+
+	holder.mutex.Lock()
+	defer holder.mutex.Unlock()
+
+	holder.fields[key] = value
+}
+
+// RequestFields returns fields of the access log which are collected for the request
+func RequestFields(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	holder, ok := ctx.Value(requestFieldsKey{}).(*requestFields)
+
+	if !ok {
+		return fields
+	}
+
+	holder.mutex.Lock()
+	defer holder.mutex.Unlock()
+
+	for key, value := range holder.fields {
+		fields[key] = value
+	}
+
+	return fields
+}
@@ -0,0 +1,59 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"shortener/logger"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretFieldsAreRedacted(t *testing.T) {
+	var buffer bytes.Buffer
+	out := logger.Log.Out
+	logger.Log.Out = &buffer
+	defer func() { logger.Log.Out = out }()
+
+	logger.Log.WithField("password", "superman").
+		WithField("Authorization", "Bearer token").
+		WithField("refreshToken", "refresh").
+		WithField("login", "batman").
+		Info("test")
+
+	entry := make(map[string]interface{})
+	require.Nil(t, json.Unmarshal(buffer.Bytes(), &entry))
+
+	assert.Equal(t, logger.Redacted, entry["password"])
+	assert.Equal(t, logger.Redacted, entry["Authorization"])
+	assert.Equal(t, logger.Redacted, entry["refreshToken"])
+	assert.Equal(t, "batman", entry["login"])
+}
+
+func TestRequestFields(t *testing.T) {
+	logger.SetRequestField(context.Background(), "ignored", true)
+
+	ctx := logger.WithRequestFields(context.Background())
+	logger.SetRequestField(ctx, "user_id", "42")
+
+	assert.Equal(t, "42", logger.RequestFields(ctx)["user_id"])
+}
+
+func TestRequestID(t *testing.T) {
+	id := logger.NewRequestID()
+
+	assert.Len(t, id, 32)
+	assert.True(t, logger.IsValidRequestID(id))
+	assert.True(t, logger.IsValidRequestID("ab-12_cd.ef:1"))
+	assert.False(t, logger.IsValidRequestID(""))
+	assert.False(t, logger.IsValidRequestID("bad id"))
+	assert.False(t, logger.IsValidRequestID("id\nforged log line"))
+
+	ctx := logger.WithRequestID(context.Background(), id)
+
+	assert.Equal(t, id, logger.RequestID(ctx))
+	assert.Equal(t, id, logger.FromContext(ctx).Data["request_id"])
+	assert.Empty(t, logger.RequestID(context.Background()))
+}
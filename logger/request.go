@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is a header with identifier of the request. It is accepted from the client
+// when valid, otherwise new identifier is generated
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits identifiers accepted from the clients, so they are safe to log and return back
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// NewRequestID generates random request identifier
+func NewRequestID() string {
+	bytes := make([]byte, 16)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(bytes)
+}
+
+// IsValidRequestID reports whether identifier received from the client could be used
+func IsValidRequestID(id string) bool {
+	return validRequestID.MatchString(id)
+}
+
+// WithRequestID stores request identifier in the context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns request identifier from the context or empty string
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// FromContext returns log entry with request identifier attached
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(Log)
+
+	if id := RequestID(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+
+	return entry
+}
@@ -4,24 +4,31 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"regexp"
 	"shortener/configuration"
 	"shortener/driver"
+	"shortener/limiter"
+	"shortener/logger"
+	"shortener/metrics"
 	"shortener/migrator"
 	"shortener/models"
 	"shortener/models/options"
 	"shortener/repository"
 	"shortener/routes"
+	"shortener/shortcode"
+	"shortener/tracking"
 	"shortener/utils"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
 const Authorization = "Authorization"
-const address = "127.0.0.1:8000"
 
 func isAuthorizedRoute(r *http.Request, rm *mux.RouteMatch) bool {
 	value := r.Header.Get(Authorization)
@@ -36,19 +43,49 @@ func isAnonRoute(r *http.Request, rm *mux.RouteMatch) bool {
 func anonUserMiddlewareGenerator(db *sql.DB) func(http.Handler) http.Handler {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	repository := repository.NewUserRepository(db)
-	anon, err := repository.FindByLoginWithContext(ctx, configuration.GetConfiguration().AnonUserLogin)
+	users := repository.NewUserRepository(db)
+	anon, err := users.FindByLoginWithContext(ctx, configuration.GetConfiguration().AnonUserLogin)
 
 	if err != nil {
-		log.Fatalln(err)
+		logger.Log.WithError(err).Fatal("unable to find anonymous user")
+	}
+
+	// visitors with the guest cookie are authorized as their guest, others share the anonymous user
+	var guestOrAnon = func(w http.ResponseWriter, r *http.Request) (*models.User, error) {
+		guestID, ok := models.GuestIDFromRequest(r)
+
+		if !ok {
+			return anon, nil
+		}
+
+		guest, err := users.FindGuestWithContext(r.Context(), guestID)
+
+		if err == nil {
+			return guest, nil
+		}
+
+		// guest is claimed by the account or deleted
+		if repository.KindOf(err) == repository.NotFound {
+			http.SetCookie(w, models.ExpiredGuestCookie())
+			return anon, nil
+		}
+
+		return nil, err
 	}
 
 	anonUserMiddleware := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			token, err := models.GenerateAuthToken(*anon)
+			user, err := guestOrAnon(w, r)
 
 			if err != nil {
-				utils.RespondWithError(&w, http.StatusInternalServerError, models.Error{err.Error()})
+				utils.RespondWithDomainError(&w, err)
+				return
+			}
+
+			token, err := models.GenerateAuthToken(*user)
+
+			if err != nil {
+				utils.RespondWithError(&w, http.StatusInternalServerError, models.NewError(err.Error()))
 				return
 			}
 
@@ -61,38 +98,80 @@ func anonUserMiddlewareGenerator(db *sql.DB) func(http.Handler) http.Handler {
 	return anonUserMiddleware
 }
 
-func withAuth(next http.Handler) http.Handler {
-	var checkAuthHeader = func(header string) (*models.User, error) {
-		r, _ := regexp.Compile("^Bearer (?P<token>.*)")
-		matches := r.FindStringSubmatch(header)
+// authMiddlewareGenerator returns middleware which checks "Bearer <access token>" or "ApiKey <key>"
+// authorization. Revoked tokens (see logout) and keys are rejected.
+func authMiddlewareGenerator(db *sql.DB) func(http.Handler) http.Handler {
+	tokens := repository.NewTokenRepository(db)
+	apiKeys := repository.NewAPIKeyRepository(db)
+	authHeaderPattern := regexp.MustCompile("^(?P<scheme>Bearer|ApiKey) (?P<token>.*)")
 
-		// whole string + token itself
-		if len(matches) != 2 {
-			return nil, errors.New("User is not authorized")
-		}
-
-		token := models.Token{Value: matches[1]}
-
-		claims, err := token.GetClaims()
+	var checkAuthHeader = func(header string) (string, string, error) {
+		matches := authHeaderPattern.FindStringSubmatch(header)
 
-		if err != nil {
-			return nil, err
+		// whole string + scheme + token itself
+		if len(matches) != 3 {
+			return "", "", errors.New("User is not authorized")
 		}
 
-		return &claims.User, nil
+		return matches[1], matches[2], nil
 	}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, err := checkAuthHeader(r.Header.Get(Authorization))
+	var withAPIKey = func(next http.Handler, w http.ResponseWriter, r *http.Request, plainKey string) {
+		user, key, err := apiKeys.AuthenticateWithContext(r.Context(), models.HashAPIKey(plainKey))
 
 		if err != nil {
-			utils.RespondWithError(&w, http.StatusUnauthorized, models.Error{err.Error()})
+			utils.RespondWithDomainError(&w, err)
 			return
 		}
 
+		logger.SetRequestField(r.Context(), "user_id", user.ID)
+		logger.SetRequestField(r.Context(), "key_id", key.ID)
 		ctx := context.WithValue(r.Context(), "user", user)
+		ctx = context.WithValue(ctx, "apiKey", key)
 		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, value, err := checkAuthHeader(r.Header.Get(Authorization))
+
+			if err != nil {
+				utils.RespondWithError(&w, http.StatusUnauthorized, models.NewError(err.Error()))
+				return
+			}
+
+			if scheme == "ApiKey" {
+				withAPIKey(next, w, r, value)
+				return
+			}
+
+			token := models.Token{Value: value}
+			claims, err := token.GetClaims()
+
+			if err != nil {
+				utils.RespondWithError(&w, http.StatusUnauthorized, models.NewError(err.Error()))
+				return
+			}
+
+			revoked, err := tokens.IsAccessTokenRevokedWithContext(r.Context(), *claims)
+
+			if err != nil {
+				utils.RespondWithDomainError(&w, err)
+				return
+			}
+
+			if revoked {
+				utils.RespondWithError(&w, http.StatusUnauthorized, models.NewErrorWithCode("token_revoked", "Token is revoked"))
+				return
+			}
+
+			user := &claims.User
+			logger.SetRequestField(r.Context(), "user_id", user.ID)
+			ctx := context.WithValue(r.Context(), "user", user)
+			ctx = context.WithValue(ctx, "claims", claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 func withOptions(next http.Handler) http.Handler {
@@ -104,54 +183,324 @@ func withOptions(next http.Handler) http.Handler {
 	})
 }
 
+// routeTemplate returns path template of the matched route
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+
+	return "unknown"
+}
+
+// withRequestID accepts X-Request-ID from the client or generates it. Identifier is stored
+// in the context and returned in the response header, so errors could be tied to log entries.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logger.RequestIDHeader)
+
+		if !logger.IsValidRequestID(id) {
+			id = logger.NewRequestID()
+		}
+
+		w.Header().Set(logger.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	})
+}
+
+// withAccessLog writes a line to the log for every request including unmatched ones.
+// Handlers add their fields (e.g. route and user id) via logger.SetRequestField.
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := utils.NewResponseRecorder(w)
+		ctx := logger.WithRequestFields(r.Context())
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		fields := logger.RequestFields(ctx)
+		fields["method"] = r.Method
+		fields["path"] = r.URL.Path
+		fields["status"] = recorder.Status
+		fields["duration_ms"] = float64(time.Since(start)) / float64(time.Millisecond)
+		fields["request_id"] = logger.RequestID(ctx)
+
+		if _, ok := fields["route"]; !ok {
+			fields["route"] = "unknown"
+		}
+
+		logger.Log.WithFields(fields).Info("request")
+	})
+}
+
+// withRouteLogging adds template of the matched route to the access log
+func withRouteLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.SetRequestField(r.Context(), "route", routeTemplate(r))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withMetrics counts requests and measures their latency by route template
+func withMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := utils.NewResponseRecorder(w)
+
+		next.ServeHTTP(recorder, r)
+
+		route := routeTemplate(r)
+
+		metrics.HTTPRequests.Inc(r.Method, route, strconv.Itoa(recorder.Status))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// registerMetrics exposes state of the long-lived objects as metrics
+func registerMetrics(db *sql.DB, usageWriter *tracking.Writer, linkRepository *repository.CachedLinkRepository) {
+	registry := metrics.DefaultRegistry
+
+	registry.NewGaugeFunc("shortener_db_open_connections", "Count of established database connections.", func() float64 {
+		return float64(db.Stats().OpenConnections)
+	})
+	registry.NewGaugeFunc("shortener_db_in_use_connections", "Count of database connections in use.", func() float64 {
+		return float64(db.Stats().InUse)
+	})
+	registry.NewGaugeFunc("shortener_db_idle_connections", "Count of idle database connections.", func() float64 {
+		return float64(db.Stats().Idle)
+	})
+	registry.NewCounterFunc("shortener_db_wait_count_total", "Count of waits for a database connection.", func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	registry.NewCounterFunc("shortener_db_wait_duration_seconds_total", "Time spent waiting for a database connection.", func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+	registry.NewCounterFunc("shortener_usage_inserts_total", "Count of saved usages.", func() float64 {
+		return float64(usageWriter.Stats().Written)
+	})
+	registry.NewCounterFunc("shortener_usage_insert_failures_total", "Count of usages which could not be saved.", func() float64 {
+		return float64(usageWriter.Stats().Failed)
+	})
+	registry.NewCounterFunc("shortener_usage_dropped_total", "Count of usages dropped because the queue is full.", func() float64 {
+		return float64(usageWriter.Stats().Dropped)
+	})
+	registry.NewCounterFunc("shortener_usage_over_quota_total", "Count of usages which are not saved because clicks quota is exceeded.", func() float64 {
+		return float64(usageWriter.Stats().OverQuota)
+	})
+	registry.NewCounterFunc("shortener_link_cache_hits_total", "Count of redirect lookups served by the cache.", func() float64 {
+		return float64(linkRepository.CacheStats().Hits)
+	})
+	registry.NewCounterFunc("shortener_link_cache_misses_total", "Count of redirect lookups missed by the cache.", func() float64 {
+		return float64(linkRepository.CacheStats().Misses)
+	})
+}
+
 func stop(err error) {
 	if err != nil {
-		log.Fatalln(err)
+		logger.Log.Fatal(err)
+	}
+}
+
+func newServer(r *mux.Router) *http.Server {
+	config := configuration.GetConfiguration()
+
+	return &http.Server{
+		Handler:           withRequestID(withAccessLog(r)),
+		Addr:              config.ListenAddress,
+		WriteTimeout:      time.Duration(config.WriteTimeout) * time.Second,
+		ReadTimeout:       time.Duration(config.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(config.ReadTimeout) * time.Second,
+		IdleTimeout:       time.Duration(config.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
 	}
 }
 
-func startServer(r *mux.Router) chan error {
-	var errs chan error
+func startServer(srv *http.Server) chan error {
+	errs := make(chan error, 1)
 
 	go func() {
-		srv := &http.Server{
-			Handler:      r,
-			Addr:         address,
-			WriteTimeout: 15 * time.Second,
-			ReadTimeout:  15 * time.Second,
+		errs <- srv.ListenAndServe()
+	}()
+
+	return errs
+}
+
+// waitForShutdown blocks until the server fails or SIGINT/SIGTERM is received.
+// Error of the server is returned, nil is returned when the signal is received
+func waitForShutdown(errs chan error) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-errs:
+		logger.Log.WithError(err).Error("server stopped")
+		return err
+	case sig := <-signals:
+		logger.Log.WithField("signal", sig.String()).Info("received signal")
+		return nil
+	}
+}
+
+// grantAdminRoles gives admin role to the users listed in ADMIN_LOGINS, so the first administrator could be created.
+// Changes are recorded to the audit log without the actor, unknown logins are skipped.
+func grantAdminRoles(db *sql.DB) {
+	users := repository.NewUserRepository(db)
+	audit := repository.NewAuditRepository(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, login := range configuration.GetConfiguration().AdminLogins {
+		log := logger.Log.WithField("login", login)
+		user, err := users.FindByLoginWithContext(ctx, login)
+
+		if repository.KindOf(err) == repository.NotFound {
+			log.Warn("admin login is not registered")
+			continue
+		}
+
+		stop(err)
+
+		if user.Role == models.RoleAdmin {
+			continue
+		}
+
+		_, err = users.SetRoleWithContext(ctx, user.ID, models.RoleAdmin)
+		stop(err)
+
+		entry := models.AuditEntry{
+			Action:     models.AuditChangeRole,
+			TargetType: models.AuditTargetUser,
+			TargetID:   user.ID,
+			Details:    map[string]interface{}{"role": models.RoleAdmin, "source": "ADMIN_LOGINS"},
+		}
+
+		if _, err = audit.CreateWithContext(ctx, entry); err != nil {
+			log.WithError(err).Error("unable to record admin role")
 		}
 
-		err := srv.ListenAndServe()
-		errs <- err
+		log.Info("admin role is granted")
+	}
+}
+
+// guestCleanupInterval is a period of the expired guests cleanup
+const guestCleanupInterval = time.Hour
+
+// startGuestCleanup periodically deletes guests older than GUEST_TTL together with their links,
+// deleted links are removed from the cache. Returned function stops the cleanup.
+func startGuestCleanup(db *sql.DB, linkRepository repository.LinksRepositoryInterface) func() {
+	users := repository.NewUserRepository(db)
+	ttl := time.Duration(configuration.GetConfiguration().GuestTTL) * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(guestCleanupInterval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := users.DeleteExpiredGuestsWithContext(ctx, time.Now().Add(-ttl))
+
+				if err != nil {
+					logger.Log.WithError(err).Error("unable to delete expired guests")
+					continue
+				}
+
+				repository.InvalidateLinks(linkRepository, deleted)
+			}
+		}
 	}()
 
-	return errs
+	return cancel
+}
+
+// shutdown stops accepting new requests, drains in-flight requests and queued usages
+// and closes the database connection. Everything should be done within SHUTDOWN_TIMEOUT.
+func shutdown(srv *http.Server, usageWriter *tracking.Writer, db *sql.DB) {
+	timeout := time.Duration(configuration.GetConfiguration().ShutdownTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Log.WithError(err).Error("unable to shutdown server gracefully")
+	}
+
+	if err := usageWriter.Close(ctx); err != nil {
+		logger.Log.WithError(err).Error("unable to save queued usages")
+	}
+
+	if err := db.Close(); err != nil {
+		logger.Log.WithError(err).Error("unable to close database connection")
+	}
+
+	logger.Log.Info("server stopped")
 }
 
 func main() {
 	db := driver.ConnectPostgreSQL()
 
 	migrator.MigrateDatabase(db)
+	grantAdminRoles(db)
 
 	r := mux.NewRouter()
 
+	r.Use(withRouteLogging)
+	r.Use(withMetrics)
 	r.Use(withOptions)
 
+	// probes and metrics are registered before the subrouters, so they do not require authorization
+	stop(routes.AddHealthRoutes(r, db))
+	r.Handle("/metrics", metrics.DefaultRegistry.Handler()).Methods("GET")
+
+	usageConfig, err := tracking.NewConfigFromConfiguration()
+	stop(err)
+
+	usageWriter := tracking.NewWriterWithQuota(
+		repository.NewUsageRepository(db),
+		repository.NewQuotaRepository(db),
+		usageConfig,
+	)
+	codes, err := shortcode.NewGeneratorFromConfiguration()
+	stop(err)
+
+	linkRepository := repository.NewCachedLinkRepository(repository.NewSQLLinkRepositoryWithGenerator(db, codes))
+	registerMetrics(db, usageWriter, linkRepository)
+	stopGuestCleanup := startGuestCleanup(db, linkRepository)
+
+	// token refresh is registered before the subrouters as well, expired access token should not hide it
+	stop(routes.AddTokenRoutes(r, db, linkRepository))
+
 	authorizedRouter := r.MatcherFunc(isAuthorizedRoute).Subrouter()
 	anonRouter := r.MatcherFunc(isAnonRoute).Subrouter()
 	anonRouter.Use(anonUserMiddlewareGenerator(db))
 
+	withAuth := authMiddlewareGenerator(db)
+	rateLimits := routes.NewRateLimits(limiter.NewMemoryStore())
+
 	for _, router := range []*mux.Router{authorizedRouter, anonRouter} {
 		router.Use(withAuth)
-		err := routes.AddProtectedRoutes(router, db)
+		err := routes.AddProtectedRoutes(router, db, usageWriter, linkRepository, rateLimits)
 		stop(err)
 	}
 
-	err := routes.AddOpenRoutes(anonRouter, db)
+	err = routes.AddOpenRoutes(anonRouter, db, linkRepository, rateLimits)
 
 	stop(err)
 
-	errs := startServer(r)
-	log.Println("server started on", address)
-	log.Fatalln(<-errs)
+	srv := newServer(r)
+	errs := startServer(srv)
+	logger.Log.WithField("address", srv.Addr).Info("server started")
+
+	err = waitForShutdown(errs)
+	stopGuestCleanup()
+	shutdown(srv, usageWriter, db)
+
+	// queued usages are saved by the shutdown, so the failed server exits only after it
+	if err != nil && err != http.ErrServerClosed {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,31 @@
+package metrics
+
+// Application metrics which are updated by middlewares and controllers
+
+// HTTPRequests counts served requests by route template
+var HTTPRequests = DefaultRegistry.NewCounterVec(
+	"shortener_http_requests_total",
+	"Count of HTTP requests by method, route template and status code.",
+	"method", "route", "status",
+)
+
+// HTTPDuration measures latency of requests by route template
+var HTTPDuration = DefaultRegistry.NewHistogramVec(
+	"shortener_http_request_duration_seconds",
+	"Latency of HTTP requests by method and route template.",
+	DefaultBuckets,
+	"method", "route",
+)
+
+// Redirects counts redirects to the links
+var Redirects = DefaultRegistry.NewCounterVec(
+	"shortener_redirects_total",
+	"Count of redirects to the links.",
+)
+
+// Logins counts login attempts by result (success, failure or throttled)
+var Logins = DefaultRegistry.NewCounterVec(
+	"shortener_logins_total",
+	"Count of login attempts by result.",
+	"result",
+)
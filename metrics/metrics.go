@@ -0,0 +1,278 @@
+package metrics
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets (in seconds) suitable for HTTP latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// labelSeparator joins label values into the key of the series
+const labelSeparator = "\xff"
+
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry holds metrics and writes them in Prometheus text format
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []collector
+}
+
+// DefaultRegistry is used by the application metrics
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (registry *Registry) register(c collector) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.collectors = append(registry.collectors, c)
+}
+
+// Write writes all metrics in Prometheus text format
+func (registry *Registry) Write(w io.Writer) {
+	registry.mutex.Lock()
+	collectors := make([]collector, len(registry.collectors))
+	copy(collectors, registry.collectors)
+	registry.mutex.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler returns handler which exposes metrics of the registry
+func (registry *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		registry.Write(w)
+	}
+}
+
+type metric struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (m *metric) writeHeader(w io.Writer) {
+	io.WriteString(w, "# HELP "+m.name+" "+m.help+"\n")
+	io.WriteString(w, "# TYPE "+m.name+" "+m.kind+"\n")
+}
+
+func (m *metric) key(values []string) string {
+	if len(values) != len(m.labels) {
+		panic("metric " + m.name + " expects " + strconv.Itoa(len(m.labels)) + " label values")
+	}
+
+	return strings.Join(values, labelSeparator)
+}
+
+// formatLabels returns labels in {name="value"} format, extra label is used by histogram buckets
+func (m *metric) formatLabels(key string, extra ...string) string {
+	var pairs []string
+
+	if len(m.labels) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, m.labels[i]+`="`+escape(value)+`"`)
+		}
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	metric
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec creates and registers CounterVec
+func (registry *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	counter := &CounterVec{
+		metric: metric{name: name, help: help, kind: "counter", labels: labels},
+		values: make(map[string]float64),
+	}
+
+	registry.register(counter)
+
+	return counter
+}
+
+// Inc increments counter with label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta to the counter with label values
+func (c *CounterVec) Add(delta float64, values ...string) {
+	key := c.key(values)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.values[key] += delta
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.writeHeader(w)
+
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		io.WriteString(w, c.name+" 0\n")
+	}
+
+	for _, key := range sortedKeys(c.values) {
+		io.WriteString(w, c.name+c.formatLabels(key)+" "+formatValue(c.values[key])+"\n")
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	metric
+	buckets []float64
+	mutex   sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates and registers HistogramVec. Buckets should be sorted
+func (registry *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	histogram := &HistogramVec{
+		metric:  metric{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+
+	registry.register(histogram)
+
+	return histogram
+}
+
+// Observe adds value to the histogram with label values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	key := h.key(values)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	series, ok := h.values[key]
+
+	if !ok {
+		series = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = series
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+
+	series.count++
+	series.sum += value
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.writeHeader(w)
+
+	keys := make([]string, 0, len(h.values))
+
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := h.values[key]
+
+		for i, bound := range h.buckets {
+			io.WriteString(w, h.name+"_bucket"+h.formatLabels(key, "le", formatValue(bound))+" "+strconv.FormatUint(series.counts[i], 10)+"\n")
+		}
+
+		io.WriteString(w, h.name+"_bucket"+h.formatLabels(key, "le", "+Inf")+" "+strconv.FormatUint(series.count, 10)+"\n")
+		io.WriteString(w, h.name+"_sum"+h.formatLabels(key)+" "+formatValue(series.sum)+"\n")
+		io.WriteString(w, h.name+"_count"+h.formatLabels(key)+" "+strconv.FormatUint(series.count, 10)+"\n")
+	}
+}
+
+// funcMetric reads its value from the function when metrics are collected
+type funcMetric struct {
+	metric
+	value func() float64
+}
+
+// NewGaugeFunc registers gauge which value is returned by the function
+func (registry *Registry) NewGaugeFunc(name string, help string, value func() float64) {
+	registry.register(&funcMetric{metric: metric{name: name, help: help, kind: "gauge"}, value: value})
+}
+
+// NewCounterFunc registers counter which value is returned by the function
+func (registry *Registry) NewCounterFunc(name string, help string, value func() float64) {
+	registry.register(&funcMetric{metric: metric{name: name, help: help, kind: "counter"}, value: value})
+}
+
+func (f *funcMetric) write(w io.Writer) {
+	f.writeHeader(w)
+	io.WriteString(w, f.name+" "+formatValue(f.value())+"\n")
+}
@@ -0,0 +1,67 @@
+package metrics_test
+
+import (
+	"bytes"
+	"shortener/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryWritesTextFormat(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	counter := registry.NewCounterVec("requests_total", "Count of requests.", "route", "status")
+	counter.Inc("/l/{id}", "301")
+	counter.Inc("/l/{id}", "301")
+	counter.Add(3, "/l", "200")
+
+	histogram := registry.NewHistogramVec("duration_seconds", "Latency.", []float64{0.1, 1}, "route")
+	histogram.Observe(0.05, "/l")
+	histogram.Observe(0.5, "/l")
+	histogram.Observe(5, "/l")
+
+	registry.NewGaugeFunc("connections", "Open connections.", func() float64 { return 7 })
+
+	var buffer bytes.Buffer
+	registry.Write(&buffer)
+
+	expected := `# HELP requests_total Count of requests.
+# TYPE requests_total counter
+requests_total{route="/l/{id}",status="301"} 2
+requests_total{route="/l",status="200"} 3
+# HELP duration_seconds Latency.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/l",le="0.1"} 1
+duration_seconds_bucket{route="/l",le="1"} 2
+duration_seconds_bucket{route="/l",le="+Inf"} 3
+duration_seconds_sum{route="/l"} 5.55
+duration_seconds_count{route="/l"} 3
+# HELP connections Open connections.
+# TYPE connections gauge
+connections 7
+`
+
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestLabelValuesAreEscaped(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounterVec("values_total", "Values.", "value")
+	counter.Inc("quote\" and \\ slash")
+
+	var buffer bytes.Buffer
+	registry.Write(&buffer)
+
+	assert.Contains(t, buffer.String(), `values_total{value="quote\" and \\ slash"} 1`)
+}
+
+func TestCounterWithoutLabelsStartsFromZero(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.NewCounterVec("redirects_total", "Redirects.")
+
+	var buffer bytes.Buffer
+	registry.Write(&buffer)
+
+	assert.Contains(t, buffer.String(), "redirects_total 0\n")
+}
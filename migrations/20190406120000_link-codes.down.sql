@@ -0,0 +1,5 @@
+alter table links drop constraint if exists links_code;
+
+alter table links drop column if exists code;
+
+drop sequence if exists links_code_seq;
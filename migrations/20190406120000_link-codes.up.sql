@@ -0,0 +1,5 @@
+create sequence if not exists links_code_seq;
+
+alter table links add column if not exists code varchar(64) default null;
+
+alter table links add constraint links_code unique (code);
@@ -0,0 +1,3 @@
+alter table links drop constraint if exists links_alias;
+
+alter table links drop column if exists alias;
@@ -0,0 +1,3 @@
+alter table links add column if not exists alias varchar(64) default null;
+
+alter table links add constraint links_alias unique (alias);
@@ -0,0 +1,5 @@
+alter table links drop column if exists clicks;
+
+alter table links drop column if exists max_clicks;
+
+alter table links drop column if exists expires_at;
@@ -0,0 +1,5 @@
+alter table links add column if not exists expires_at timestamptz default null;
+
+alter table links add column if not exists max_clicks integer default null;
+
+alter table links add column if not exists clicks integer not null default 0;
@@ -0,0 +1 @@
+alter table links drop column if exists password;
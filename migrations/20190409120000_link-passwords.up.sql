@@ -0,0 +1 @@
+alter table links add column if not exists password varchar(512) default null;
@@ -0,0 +1,23 @@
+create table if not exists refresh_tokens (
+  id uuid default uuid_generate_v4(),
+  user_id uuid not null,
+  family_id uuid not null,
+  token_hash varchar(64) not null,
+  created timestamp default NOW(),
+  expires_at timestamptz not null,
+  used_at timestamptz default null,
+  revoked_at timestamptz default null,
+
+  primary key(id),
+  constraint refresh_tokens_token_hash unique (token_hash),
+  constraint refresh_tokens_user_id foreign key (user_id) references users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+create index if not exists refresh_tokens_family_id on refresh_tokens (family_id);
+
+create table if not exists revoked_access_tokens (
+  jti varchar(64) not null,
+  expires_at timestamptz not null,
+
+  primary key(jti)
+);
@@ -0,0 +1,16 @@
+create table if not exists api_keys (
+  id uuid default uuid_generate_v4(),
+  user_id uuid not null,
+  name varchar(256) not null,
+  prefix varchar(16) not null,
+  key_hash varchar(64) not null,
+  scopes text[] not null default '{}',
+  created timestamp default NOW(),
+  expires_at timestamptz default null,
+  last_used_at timestamptz default null,
+  revoked_at timestamptz default null,
+
+  primary key(id),
+  constraint api_keys_key_hash unique (key_hash),
+  constraint api_keys_user_id foreign key (user_id) references users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
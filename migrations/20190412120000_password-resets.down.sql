@@ -0,0 +1,2 @@
+drop table if exists password_reset_tokens;
+alter table users drop column if exists tokens_valid_after;
@@ -0,0 +1,14 @@
+alter table users add column if not exists tokens_valid_after timestamptz default null;
+
+create table if not exists password_reset_tokens (
+  id uuid default uuid_generate_v4(),
+  user_id uuid not null,
+  token_hash varchar(64) not null,
+  created timestamp default NOW(),
+  expires_at timestamptz not null,
+  used_at timestamptz default null,
+
+  primary key(id),
+  constraint password_reset_tokens_token_hash unique (token_hash),
+  constraint password_reset_tokens_user_id foreign key (user_id) references users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,3 @@
+drop table if exists audit_log;
+alter table users drop column if exists disabled_at;
+alter table users drop column if exists role;
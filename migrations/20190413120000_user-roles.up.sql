@@ -0,0 +1,17 @@
+alter table users add column if not exists role varchar(32) not null default 'user';
+alter table users add column if not exists disabled_at timestamptz default null;
+
+create table if not exists audit_log (
+  id uuid default uuid_generate_v4(),
+  actor_id uuid default null,
+  action varchar(64) not null,
+  target_type varchar(32) not null,
+  target_id varchar(256) not null,
+  details json default null,
+  created timestamp default NOW(),
+
+  primary key(id),
+  constraint audit_log_actor_id foreign key (actor_id) references users(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+create index if not exists audit_log_created on audit_log (created);
@@ -0,0 +1,4 @@
+alter table links drop column if exists workspace_id;
+drop table if exists workspace_invitations;
+drop table if exists workspace_members;
+drop table if exists workspaces;
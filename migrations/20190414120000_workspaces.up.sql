@@ -0,0 +1,40 @@
+create table if not exists workspaces (
+  id uuid default uuid_generate_v4(),
+  name varchar(256) not null,
+  created timestamp default NOW(),
+
+  primary key(id)
+);
+
+create table if not exists workspace_members (
+  workspace_id uuid not null,
+  user_id uuid not null,
+  role varchar(16) not null,
+  created timestamp default NOW(),
+
+  primary key(workspace_id, user_id),
+  constraint workspace_members_workspace_id foreign key (workspace_id) references workspaces(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  constraint workspace_members_user_id foreign key (user_id) references users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+create index if not exists workspace_members_user on workspace_members (user_id);
+
+create table if not exists workspace_invitations (
+  id uuid default uuid_generate_v4(),
+  workspace_id uuid not null,
+  user_id uuid not null,
+  invited_by uuid default null,
+  role varchar(16) not null,
+  created timestamp default NOW(),
+  expires_at timestamptz not null,
+
+  primary key(id),
+  constraint workspace_invitations_workspace_user unique (workspace_id, user_id),
+  constraint workspace_invitations_workspace_id foreign key (workspace_id) references workspaces(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  constraint workspace_invitations_user_id foreign key (user_id) references users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  constraint workspace_invitations_invited_by foreign key (invited_by) references users(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+alter table links add column if not exists workspace_id uuid default null;
+alter table links add constraint links_workspace_id foreign key (workspace_id) references workspaces(id) ON DELETE CASCADE ON UPDATE CASCADE;
+create index if not exists links_workspace on links (workspace_id);
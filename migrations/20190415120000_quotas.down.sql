@@ -0,0 +1,3 @@
+drop index if exists links_user_created;
+drop table if exists click_counters;
+alter table users drop column if exists plan;
@@ -0,0 +1,12 @@
+alter table users add column if not exists plan varchar(32) not null default 'free';
+
+create table if not exists click_counters (
+  user_id uuid not null,
+  month date not null,
+  clicks bigint not null default 0,
+
+  primary key(user_id, month),
+  constraint click_counters_user_id foreign key (user_id) references users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+create index if not exists links_user_created on links (user_id, created);
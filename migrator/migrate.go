@@ -1,20 +1,58 @@
 package migrator
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"io/ioutil"
 	"os"
+	"shortener/logger"
 
 	_ "github.com/golang-migrate/migrate/source/file"
 
 	"github.com/golang-migrate/migrate"
 	"github.com/golang-migrate/migrate/database/postgres"
+	"github.com/golang-migrate/migrate/source"
 )
 
+// Directory with migrations of the application
+const Directory = "./migrations"
+
 // MigrateDatabase is used to migrate database to the next state
 func MigrateDatabase(db *sql.DB) {
-	directory := "./migrations"
-	MigrateDatabaseFromDirectory(db, directory, 1)
+	MigrateDatabaseFromDirectory(db, Directory, 1)
+}
+
+// ExpectedVersion returns version of the latest migration from the directory
+func ExpectedVersion(directory string) (uint, error) {
+	var version uint
+
+	files, err := ioutil.ReadDir(directory)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, file := range files {
+		migration, err := source.Parse(file.Name())
+
+		if err == nil && migration.Version > version {
+			version = migration.Version
+		}
+	}
+
+	return version, nil
+}
+
+// CurrentVersion returns version of the database schema and whether the last migration failed.
+// sql.ErrNoRows is returned when migrations are not applied.
+func CurrentVersion(ctx context.Context, db *sql.DB) (uint, bool, error) {
+	var version int64
+	var dirty bool
+
+	statement := "select version, dirty from " + postgres.DefaultMigrationsTable + " limit 1"
+	err := db.QueryRowContext(ctx, statement).Scan(&version, &dirty)
+
+	return uint(version), dirty, err
 }
 
 // MigrateDatabaseFromDirectory is used to migrate database to the next state.
@@ -23,7 +61,7 @@ func MigrateDatabaseFromDirectory(db *sql.DB, directory string, direction int) {
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 
 	if err != nil {
-		log.Fatal(err)
+		logger.Log.Fatal(err)
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
@@ -33,20 +71,20 @@ func MigrateDatabaseFromDirectory(db *sql.DB, directory string, direction int) {
 	)
 
 	if err != nil {
-		log.Fatal(err)
+		logger.Log.Fatal(err)
 	}
 
-	log.Println("start migrations")
+	logger.Log.Info("start migrations")
 
 	for {
-		log.Println("applying next migration")
+		logger.Log.Debug("applying next migration")
 		err = m.Steps(1 * direction)
 		if err == os.ErrNotExist {
 			break
 		} else if err != nil {
-			log.Fatal(err)
+			logger.Log.Fatal(err)
 		}
 	}
 
-	log.Println("migrations applied")
+	logger.Log.Info("migrations applied")
 }
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Scopes of the API keys. Keys without scopes have full access
+const (
+	ScopeLinksRead  = "links:read"
+	ScopeLinksWrite = "links:write"
+	ScopeStatsRead  = "stats:read"
+)
+
+// APIKeyPrefix is added to the generated keys, so they are easy to recognize
+const APIKeyPrefix = "sk_"
+
+// apiKeyVisiblePrefix is a length of the key prefix which is stored to distinguish keys in the list
+const apiKeyVisiblePrefix = 10
+
+const maxAPIKeyNameLength = 256
+
+var knownScopes = map[string]bool{
+	ScopeLinksRead:  true,
+	ScopeLinksWrite: true,
+	ScopeStatsRead:  true,
+}
+
+// APIKey is used by scripts instead of the user password. Only hash of the key is stored,
+// plain key is returned once when the key is created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Created    time.Time  `json:"created"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	Key        string     `json:"key,omitempty"`
+	Hash       string     `json:"-"`
+}
+
+// Populate reads user provided fields of the key (name, scopes and expiration date)
+func (k *APIKey) Populate(r *http.Request) error {
+	var request struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return err
+	}
+
+	k.Name = request.Name
+	k.Scopes = request.Scopes
+	k.ExpiresAt = request.ExpiresAt
+
+	return nil
+}
+
+// Validate checks user provided fields of the key. ValidationErrors are returned with all invalid fields
+func (k *APIKey) Validate() error {
+	var errs ValidationErrors
+
+	if k.Name == "" || len(k.Name) > maxAPIKeyNameLength {
+		errs = append(errs, FieldError{"name", "invalid_length", "Name should be between 1 and 256 characters"})
+	}
+
+	for _, scope := range k.Scopes {
+		if !knownScopes[scope] {
+			errs = append(errs, FieldError{"scopes", "unknown", "Scope " + scope + " is unknown"})
+		}
+	}
+
+	if k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now()) {
+		errs = append(errs, FieldError{"expiresAt", "in_past", "Expiration date should be in the future"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Generate creates random key. Plain key is kept in the Key field until it is sent to the user
+func (k *APIKey) Generate() error {
+	secret, err := randomHex(32)
+
+	if err != nil {
+		return err
+	}
+
+	k.Key = APIKeyPrefix + secret
+	k.Prefix = k.Key[:apiKeyVisiblePrefix]
+	k.Hash = HashAPIKey(k.Key)
+
+	return nil
+}
+
+// HasScope reports whether key allows the action. Keys without scopes allow everything
+func (k *APIKey) HasScope(scope string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+
+	for _, allowed := range k.Scopes {
+		if allowed == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HashAPIKey returns hash of the plain key which is stored
+func HashAPIKey(plain string) string {
+	return hashSecret(plain)
+}
+
+// NewAPIKeyFromContext returns API key which is used to authorize the request.
+// Nil is returned when request is authorized by the token.
+func NewAPIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value("apiKey").(*APIKey)
+
+	return key
+}
+
+// HasScopeInContext reports whether request is allowed to perform the action.
+// Requests which are authorized by the tokens have all scopes.
+func HasScopeInContext(ctx context.Context, scope string) bool {
+	key := NewAPIKeyFromContext(ctx)
+
+	return key == nil || key.HasScope(scope)
+}
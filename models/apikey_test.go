@@ -0,0 +1,43 @@
+package models_test
+
+import (
+	"context"
+	"shortener/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyGenerate(t *testing.T) {
+	var key models.APIKey
+
+	require.Nil(t, key.Generate())
+
+	assert.True(t, strings.HasPrefix(key.Key, models.APIKeyPrefix))
+	assert.True(t, strings.HasPrefix(key.Key, key.Prefix))
+	assert.Equal(t, models.HashAPIKey(key.Key), key.Hash)
+	assert.NotContains(t, key.Hash, key.Key)
+}
+
+func TestAPIKeyValidate(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	assert.Nil(t, (&models.APIKey{Name: "ci", Scopes: []string{models.ScopeLinksWrite}}).Validate())
+
+	err := (&models.APIKey{Scopes: []string{"links:delete"}, ExpiresAt: &past}).Validate()
+	require.IsType(t, models.ValidationErrors{}, err)
+	assert.Len(t, err.(models.ValidationErrors), 3)
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	readOnly := &models.APIKey{Scopes: []string{models.ScopeLinksRead}}
+	ctx := context.WithValue(context.Background(), "apiKey", readOnly)
+
+	assert.True(t, models.HasScopeInContext(ctx, models.ScopeLinksRead))
+	assert.False(t, models.HasScopeInContext(ctx, models.ScopeLinksWrite))
+	assert.True(t, (&models.APIKey{}).HasScope(models.ScopeStatsRead), "key without scopes should have full access")
+	assert.True(t, models.HasScopeInContext(context.Background(), models.ScopeLinksWrite), "tokens should have full access")
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Actions of the administrators which are recorded to the audit log
+const (
+	AuditListUsers   = "users.list"
+	AuditViewUser    = "users.view"
+	AuditDisableUser = "users.disable"
+	AuditEnableUser  = "users.enable"
+	AuditChangeRole  = "users.role"
+	AuditChangePlan  = "users.plan"
+	AuditViewLink    = "links.view"
+	AuditDeleteLink  = "links.delete"
+	AuditListAudit   = "audit.list"
+)
+
+// Types of the audit log targets
+const (
+	AuditTargetUser  = "user"
+	AuditTargetLink  = "link"
+	AuditTargetAudit = "audit"
+)
+
+// AuditEntry records action of the administrator. Actor is empty when the administrator is deleted
+// or the action is performed at startup (see ADMIN_LOGINS)
+type AuditEntry struct {
+	ID         string                 `json:"id"`
+	ActorID    string                 `json:"actorId,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"targetType"`
+	TargetID   string                 `json:"targetId"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	Created    time.Time              `json:"created"`
+}
@@ -1,11 +1,47 @@
 package models
 
-// Error struct
+import "strings"
+
+// Error struct is sent to the client. Code is stable and should be used by clients
+// to distinguish errors, message is human readable and could be changed.
 type Error struct {
-	Message string `json:"message"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+	// RequestID is filled when error is sent to the client, see utils.RespondWithError
+	RequestID string `json:"requestId,omitempty"`
 }
 
-// NewError creates new Error object
+// NewError creates new Error object. Code is derived from the response status when it is empty
 func NewError(message string) Error {
-	return Error{message}
+	return Error{Message: message}
+}
+
+// NewErrorWithCode creates new Error object with specific code
+func NewErrorWithCode(code string, message string) Error {
+	return Error{Code: code, Message: message}
+}
+
+// FieldError describes invalid field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors is a list of invalid fields
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+
+	for _, err := range errs {
+		messages = append(messages, err.Message)
+	}
+
+	return strings.Join(messages, "; ")
 }
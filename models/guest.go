@@ -0,0 +1,71 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"shortener/configuration"
+	"strings"
+	"time"
+)
+
+// GuestCookieName is a name of the cookie with the signed id of the guest
+const GuestCookieName = "guest"
+
+// IsGuest reports whether user is a guest of the anonymous visitor
+func (user *User) IsGuest() bool {
+	return user.Role == RoleGuest
+}
+
+// signGuestID returns signature of the guest id using token secret
+func signGuestID(id string) string {
+	mac := hmac.New(sha256.New, []byte(configuration.GetConfiguration().TokenSecret))
+	mac.Write([]byte(GuestCookieName + ":" + id))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewGuestCookie returns cookie with the signed id of the guest
+func NewGuestCookie(guest User) *http.Cookie {
+	ttl := configuration.GetConfiguration().GuestTTL
+
+	return &http.Cookie{
+		Name:     GuestCookieName,
+		Value:    guest.ID + "." + signGuestID(guest.ID),
+		Path:     "/",
+		MaxAge:   ttl,
+		Expires:  time.Now().Add(time.Second * time.Duration(ttl)),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// ExpiredGuestCookie removes guest cookie, e.g. when links of the guest are claimed
+func ExpiredGuestCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     GuestCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// GuestIDFromRequest returns id of the guest from the cookie. False is returned when cookie is missing or signature is wrong
+func GuestIDFromRequest(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(GuestCookieName)
+
+	if err != nil {
+		return "", false
+	}
+
+	parts := strings.SplitN(cookie.Value, ".", 2)
+
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(signGuestID(parts[0]))) {
+		return "", false
+	}
+
+	return parts[0], true
+}
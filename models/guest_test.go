@@ -0,0 +1,32 @@
+package models_test
+
+import (
+	"net/http/httptest"
+	"shortener/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuestCookie(t *testing.T) {
+	guest := models.User{ID: "b3e6ad43-0e9c-4a36-9f2b-2a3b4fa3c5d1", Role: models.RoleGuest}
+	assert.True(t, guest.IsGuest())
+
+	r := httptest.NewRequest("GET", "/l", nil)
+	r.AddCookie(models.NewGuestCookie(guest))
+
+	id, ok := models.GuestIDFromRequest(r)
+	assert.True(t, ok)
+	assert.Equal(t, guest.ID, id)
+
+	forged := models.NewGuestCookie(guest)
+	forged.Value = "00000000-0000-0000-0000-000000000000" + forged.Value[len(guest.ID):]
+	r = httptest.NewRequest("GET", "/l", nil)
+	r.AddCookie(forged)
+
+	_, ok = models.GuestIDFromRequest(r)
+	assert.False(t, ok, "cookie with foreign id should be rejected")
+
+	_, ok = models.GuestIDFromRequest(httptest.NewRequest("GET", "/l", nil))
+	assert.False(t, ok, "request without cookie should not have a guest")
+}
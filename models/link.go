@@ -3,19 +3,156 @@ package models
 import (
 	"encoding/json"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 )
 
 // Link struct represents link
 type Link struct {
-	Created     time.Time `json:"created"`
-	ID          string    `json:"id"`
-	URL         string    `json:"url"`
-	UsagesCount int64     `json:"usagesCount"`
-	UserID      string    `json:"userId"`
-	Usages      []Usage   `json:"usages,omitempty"`
+	Alias       string     `json:"alias,omitempty"`
+	Clicks      int64      `json:"clicks"`
+	Code        string     `json:"code"`
+	Created     time.Time  `json:"created"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	ID          string     `json:"id"`
+	MaxClicks   int64      `json:"maxClicks,omitempty"`
+	Password    string     `json:"password,omitempty"`
+	Protected   bool       `json:"protected"`
+	Status      string     `json:"status"`
+	URL         string     `json:"url"`
+	UsagesCount int64      `json:"usagesCount"`
+	UserID      string     `json:"userId"`
+	WorkspaceID string     `json:"workspaceId,omitempty"`
+	Usages      []Usage    `json:"usages,omitempty"`
+}
+
+// LinkActive is a status of the link which could be visited
+const LinkActive = "active"
+
+// LinkExpired is a status of the link which is expired by date or by clicks count
+const LinkExpired = "expired"
+
+const minAliasLength = 3
+const maxAliasLength = 32
+
+var aliasPattern = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+// reservedAliases could not be used as aliases because they clash with the routes
+var reservedAliases = map[string]bool{
+	"admin":   true,
+	"api":     true,
+	"healthz": true,
+	"l":       true,
+	"login":   true,
+	"logout":  true,
+	"metrics": true,
+	"readyz":  true,
+	"stats":   true,
+	"users":   true,
 }
 
 func (l *Link) Populate(r *http.Request) error {
 	return json.NewDecoder(r.Body).Decode(&l)
 }
+
+// CleanPrivateFields removes private fields (e.g. Password) from link object
+func (l *Link) CleanPrivateFields() {
+	l.Password = ""
+}
+
+// RestoreImmutableFields reverts fields which could not be changed by user
+func (l *Link) RestoreImmutableFields(original Link) {
+	l.Clicks = original.Clicks
+	l.Code = original.Code
+	l.Created = original.Created
+	l.ID = original.ID
+	l.Status = original.Status
+	l.UsagesCount = original.UsagesCount
+	l.UserID = original.UserID
+	l.WorkspaceID = original.WorkspaceID
+	l.Usages = original.Usages
+}
+
+// Validate checks user provided fields of the link. ValidationErrors are returned with all invalid fields
+func (l *Link) Validate() error {
+	return l.validate(nil)
+}
+
+// ValidateChanges checks only the fields which are changed comparing to the original link,
+// so the link which is already expired could be updated without changing its expiration date.
+// ValidationErrors are returned with all invalid fields
+func (l *Link) ValidateChanges(original Link) error {
+	return l.validate(&original)
+}
+
+// validate checks fields of the link, fields equal to the original ones are skipped when original is passed
+func (l *Link) validate(original *Link) error {
+	var errs ValidationErrors
+
+	if l.MaxClicks < 0 {
+		errs = append(errs, FieldError{"maxClicks", "negative", "Max clicks should not be negative"})
+	}
+
+	expiresAtChanged := original == nil || !sameTime(l.ExpiresAt, original.ExpiresAt)
+
+	if expiresAtChanged && l.ExpiresAt != nil && l.ExpiresAt.Before(time.Now()) {
+		errs = append(errs, FieldError{"expiresAt", "in_past", "Expiration date should be in the future"})
+	}
+
+	if l.Alias != "" && (original == nil || l.Alias != original.Alias) {
+		if err := ValidateAlias(l.Alias); err != nil {
+			errs = append(errs, err.(FieldError))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// sameTime reports whether both times are nil or equal
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+// IsExpired reports whether link is expired by date or by clicks count
+func (l *Link) IsExpired(now time.Time) bool {
+	if l.ExpiresAt != nil && !now.Before(*l.ExpiresAt) {
+		return true
+	}
+
+	return l.MaxClicks > 0 && l.Clicks >= l.MaxClicks
+}
+
+// UpdateStatus sets status of the link
+func (l *Link) UpdateStatus(now time.Time) {
+	if l.IsExpired(now) {
+		l.Status = LinkExpired
+	} else {
+		l.Status = LinkActive
+	}
+}
+
+// ValidateAlias checks that alias could be used as a path of the link. FieldError is returned
+func ValidateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return FieldError{"alias", "invalid_length", "Alias length should be between 3 and 32 characters"}
+	}
+
+	if !aliasPattern.MatchString(alias) {
+		return FieldError{"alias", "invalid_characters", "Alias could contain only latin letters, digits, '-' and '_'"}
+	}
+
+	if reservedAliases[strings.ToLower(alias)] {
+		return FieldError{"alias", "reserved", "Alias " + alias + " is reserved"}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package models_test
+
+import (
+	"shortener/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		valid bool
+	}{
+		{"spring-sale", true},
+		{"Spring_Sale_2019", true},
+		{"ab", false},
+		{"this-alias-is-definitely-too-long-for-us", false},
+		{"spring sale", false},
+		{"spring/sale", false},
+		{"users", false},
+		{"Users", false},
+	}
+
+	for _, test := range tests {
+		err := models.ValidateAlias(test.alias)
+
+		if test.valid {
+			assert.Nil(t, err, "alias "+test.alias+" should be valid")
+		} else {
+			assert.Error(t, err, "alias "+test.alias+" should be invalid")
+		}
+	}
+}
+
+func TestLinkStatus(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		link   models.Link
+		status string
+	}{
+		{"should be active without limits", models.Link{}, models.LinkActive},
+		{"should be active before expiration date", models.Link{ExpiresAt: &future}, models.LinkActive},
+		{"should be expired after expiration date", models.Link{ExpiresAt: &past}, models.LinkExpired},
+		{"should be active when clicks are left", models.Link{MaxClicks: 2, Clicks: 1}, models.LinkActive},
+		{"should be expired when clicks are consumed", models.Link{MaxClicks: 2, Clicks: 2}, models.LinkExpired},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			test.link.UpdateStatus(now)
+
+			assert.Equal(t, test.status, test.link.Status)
+		})
+	}
+}
+
+func TestLinkValidate(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	link := models.Link{Alias: "a", ExpiresAt: &past, MaxClicks: -1}
+
+	err := link.Validate()
+
+	require.IsType(t, models.ValidationErrors{}, err)
+
+	fields := make([]string, 0)
+
+	for _, fieldErr := range err.(models.ValidationErrors) {
+		fields = append(fields, fieldErr.Field)
+	}
+
+	assert.Equal(t, []string{"maxClicks", "expiresAt", "alias"}, fields)
+	assert.Nil(t, (&models.Link{Alias: "valid-alias"}).Validate())
+}
+
+func TestLinkValidateChanges(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	original := models.Link{Alias: "api", ExpiresAt: &past}
+
+	link := original
+	link.URL = "https://example.com"
+	assert.Nil(t, link.ValidateChanges(original), "unchanged fields should not be validated")
+
+	expiresAt := past.Add(-time.Hour)
+	link.ExpiresAt = &expiresAt
+	link.Alias = "a"
+
+	err := link.ValidateChanges(original)
+
+	require.IsType(t, models.ValidationErrors{}, err)
+	assert.Len(t, err.(models.ValidationErrors), 2)
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"shortener/configuration"
+	"time"
+)
+
+// Plans of the users. Limits of the free plan are configured, unlimited plan does not have limits
+const (
+	PlanFree      = "free"
+	PlanUnlimited = "unlimited"
+)
+
+var knownPlans = map[string]bool{
+	PlanFree:      true,
+	PlanUnlimited: true,
+}
+
+// Quota represents limits of the user, zero value means that there is no limit
+type Quota struct {
+	MaxLinks          int64
+	MaxLinksPerDay    int64
+	MaxClicksPerMonth int64
+}
+
+// QuotaCounter reports consumption of the single limit. Limit and remaining are omitted when there is no limit
+type QuotaCounter struct {
+	Used      int64      `json:"used"`
+	Limit     int64      `json:"limit,omitempty"`
+	Remaining *int64     `json:"remaining,omitempty"`
+	ResetsAt  *time.Time `json:"resetsAt,omitempty"`
+}
+
+// QuotaUsage reports current consumption of the quota by the user
+type QuotaUsage struct {
+	Plan            string       `json:"plan"`
+	Links           QuotaCounter `json:"links"`
+	LinksToday      QuotaCounter `json:"linksToday"`
+	ClicksThisMonth QuotaCounter `json:"clicksThisMonth"`
+}
+
+// ValidatePlan checks that plan is known. FieldError is returned
+func ValidatePlan(plan string) error {
+	if !knownPlans[plan] {
+		return FieldError{"plan", "unknown_plan", "Plan should be one of free or unlimited"}
+	}
+
+	return nil
+}
+
+// NewQuota returns limits of the plan. Shared anonymous user and guests of the anonymous visitors
+// are limited only by the count of links per day
+func NewQuota(plan string, login string, role string) Quota {
+	config := configuration.GetConfiguration()
+
+	if login == config.AnonUserLogin || role == RoleGuest {
+		return Quota{MaxLinksPerDay: int64(config.QuotaAnonMaxLinksPerDay)}
+	}
+
+	if plan == PlanUnlimited {
+		return Quota{}
+	}
+
+	return Quota{
+		MaxLinks:          int64(config.QuotaMaxLinks),
+		MaxLinksPerDay:    int64(config.QuotaMaxLinksPerDay),
+		MaxClicksPerMonth: int64(config.QuotaMaxClicksPerMonth),
+	}
+}
+
+// NewQuotaCounter creates counter of the used quota. Remaining quota is never negative
+func NewQuotaCounter(used int64, limit int64, resetsAt *time.Time) QuotaCounter {
+	counter := QuotaCounter{Used: used, Limit: limit, ResetsAt: resetsAt}
+
+	if limit > 0 {
+		remaining := limit - used
+
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		counter.Remaining = &remaining
+	}
+
+	return counter
+}
+
+// IsExceeded reports whether there is no remaining quota
+func (counter QuotaCounter) IsExceeded() bool {
+	return counter.Remaining != nil && *counter.Remaining == 0
+}
+
+// AddLink counts created link in the total and daily counters
+func (usage *QuotaUsage) AddLink() {
+	usage.Links = NewQuotaCounter(usage.Links.Used+1, usage.Links.Limit, usage.Links.ResetsAt)
+	usage.LinksToday = NewQuotaCounter(usage.LinksToday.Used+1, usage.LinksToday.Limit, usage.LinksToday.ResetsAt)
+}
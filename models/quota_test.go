@@ -0,0 +1,60 @@
+package models_test
+
+import (
+	"shortener/configuration"
+	"shortener/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQuota(t *testing.T) {
+	config := configuration.GetConfiguration()
+
+	free := models.NewQuota(models.PlanFree, "user", models.RoleUser)
+	assert.Equal(t, int64(config.QuotaMaxLinks), free.MaxLinks)
+	assert.Equal(t, int64(config.QuotaMaxLinksPerDay), free.MaxLinksPerDay)
+	assert.Equal(t, int64(config.QuotaMaxClicksPerMonth), free.MaxClicksPerMonth)
+
+	assert.Equal(t, models.Quota{}, models.NewQuota(models.PlanUnlimited, "user", models.RoleUser))
+
+	anon := models.NewQuota(models.PlanUnlimited, config.AnonUserLogin, models.RoleUser)
+	assert.Equal(t, models.Quota{MaxLinksPerDay: int64(config.QuotaAnonMaxLinksPerDay)}, anon, "anonymous user should be limited only by day")
+
+	guest := models.NewQuota(models.PlanFree, "guest-1", models.RoleGuest)
+	assert.Equal(t, models.Quota{MaxLinksPerDay: int64(config.QuotaAnonMaxLinksPerDay)}, guest, "guest should have quota of the anonymous user")
+}
+
+func TestQuotaCounter(t *testing.T) {
+	counter := models.NewQuotaCounter(3, 5, nil)
+	require.NotNil(t, counter.Remaining)
+	assert.Equal(t, int64(2), *counter.Remaining)
+	assert.False(t, counter.IsExceeded())
+
+	counter = models.NewQuotaCounter(7, 5, nil)
+	assert.Equal(t, int64(0), *counter.Remaining, "remaining quota should not be negative")
+	assert.True(t, counter.IsExceeded())
+
+	counter = models.NewQuotaCounter(7, 0, nil)
+	assert.Nil(t, counter.Remaining)
+	assert.False(t, counter.IsExceeded(), "counter without limit should not be exceeded")
+}
+
+func TestQuotaUsageAddLink(t *testing.T) {
+	usage := models.QuotaUsage{
+		Links:      models.NewQuotaCounter(1, 2, nil),
+		LinksToday: models.NewQuotaCounter(0, 1, nil),
+	}
+
+	usage.AddLink()
+
+	assert.Equal(t, int64(2), usage.Links.Used)
+	assert.True(t, usage.Links.IsExceeded())
+	assert.True(t, usage.LinksToday.IsExceeded())
+}
+
+func TestValidatePlan(t *testing.T) {
+	assert.Nil(t, models.ValidatePlan(models.PlanUnlimited))
+	assert.IsType(t, models.FieldError{}, models.ValidatePlan("gold"))
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// StatsBuckets are allowed sizes of the time series buckets
+var StatsBuckets = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+const defaultStatsBucket = "day"
+const defaultStatsRange = 7 * 24 * time.Hour
+const maxStatsPoints = 1000
+
+// StatsQuery represents parameters of the link statistics
+type StatsQuery struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Bucket string    `json:"bucket"`
+}
+
+// StatsPoint represents clicks count in the time series bucket
+type StatsPoint struct {
+	Time   time.Time `json:"time"`
+	Clicks int64     `json:"clicks"`
+}
+
+// StatsValue represents clicks count for the value of the dimension (e.g. referrer)
+type StatsValue struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// LinkStats represents statistics of the link usages
+type LinkStats struct {
+	StatsQuery
+	Total            int64        `json:"total"`
+	Series           []StatsPoint `json:"series"`
+	Referrers        []StatsValue `json:"referrers"`
+	Browsers         []StatsValue `json:"browsers"`
+	OperatingSystems []StatsValue `json:"operatingSystems"`
+	Countries        []StatsValue `json:"countries"`
+}
+
+// NewStatsQueryFromRequest parses "from", "to" (RFC3339) and "bucket" (hour, day or week) parameters.
+// Last seven days are used by default. FieldError is returned for the invalid parameter.
+func NewStatsQueryFromRequest(r *http.Request) (StatsQuery, error) {
+	var err error
+	query := StatsQuery{
+		To:     time.Now(),
+		Bucket: defaultStatsBucket,
+	}
+
+	if value := r.FormValue("to"); value != "" {
+		if query.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return query, FieldError{"to", "invalid_format", "Parameter 'to' should be in RFC3339 format"}
+		}
+	}
+
+	query.From = query.To.Add(-defaultStatsRange)
+
+	if value := r.FormValue("from"); value != "" {
+		if query.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return query, FieldError{"from", "invalid_format", "Parameter 'from' should be in RFC3339 format"}
+		}
+	}
+
+	if value := r.FormValue("bucket"); value != "" {
+		query.Bucket = value
+	}
+
+	bucketSize, ok := StatsBuckets[query.Bucket]
+
+	if !ok {
+		return query, FieldError{"bucket", "unknown", "Parameter 'bucket' should be one of: hour, day, week"}
+	}
+
+	if !query.From.Before(query.To) {
+		return query, FieldError{"from", "after_to", "Parameter 'from' should be before 'to'"}
+	}
+
+	if query.To.Sub(query.From)/bucketSize > maxStatsPoints {
+		return query, FieldError{"bucket", "too_many_points", "Range is too big for the bucket size"}
+	}
+
+	return query, nil
+}
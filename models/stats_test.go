@@ -0,0 +1,64 @@
+package models_test
+
+import (
+	"net/http/httptest"
+	"shortener/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStatsQueryFromRequest(t *testing.T) {
+	t.Run("should use last seven days by day by default", func(t *testing.T) {
+		query, err := models.NewStatsQueryFromRequest(httptest.NewRequest("GET", "/l/id/stats", nil))
+
+		require.Nil(t, err)
+		assert.Equal(t, "day", query.Bucket)
+		assert.Equal(t, 7*24*time.Hour, query.To.Sub(query.From))
+		assert.WithinDuration(t, time.Now(), query.To, time.Minute)
+	})
+
+	t.Run("should parse range and bucket", func(t *testing.T) {
+		query, err := models.NewStatsQueryFromRequest(httptest.NewRequest("GET", "/l/id/stats?from=2019-04-01T00:00:00Z&to=2019-04-02T12:00:00Z&bucket=hour", nil))
+
+		require.Nil(t, err)
+		assert.Equal(t, "hour", query.Bucket)
+		assert.Equal(t, time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC), query.From.UTC())
+		assert.Equal(t, time.Date(2019, 4, 2, 12, 0, 0, 0, time.UTC), query.To.UTC())
+	})
+
+	t.Run("should count default range from the end of the range", func(t *testing.T) {
+		query, err := models.NewStatsQueryFromRequest(httptest.NewRequest("GET", "/l/id/stats?to=2019-04-08T00:00:00Z", nil))
+
+		require.Nil(t, err)
+		assert.Equal(t, time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC), query.From.UTC())
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		field string
+		code  string
+	}{
+		{"should reject invalid 'from'", "from=yesterday", "from", "invalid_format"},
+		{"should reject invalid 'to'", "to=2019-04-01", "to", "invalid_format"},
+		{"should reject unknown bucket", "bucket=month", "bucket", "unknown"},
+		{"should reject 'from' after 'to'", "from=2019-04-02T00:00:00Z&to=2019-04-01T00:00:00Z", "from", "after_to"},
+		{"should reject empty range", "from=2019-04-01T00:00:00Z&to=2019-04-01T00:00:00Z", "from", "after_to"},
+		{"should reject too many points", "from=2018-01-01T00:00:00Z&to=2019-04-01T00:00:00Z&bucket=hour", "bucket", "too_many_points"},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			_, err := models.NewStatsQueryFromRequest(httptest.NewRequest("GET", "/l/id/stats?"+test.query, nil))
+
+			require.IsType(t, models.FieldError{}, err)
+			assert.Equal(t, test.field, err.(models.FieldError).Field)
+			assert.Equal(t, test.code, err.(models.FieldError).Code)
+		})
+	}
+}
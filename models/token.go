@@ -1,6 +1,10 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"shortener/configuration"
@@ -9,28 +13,138 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
-// Token struct represents JWT token
+// Token struct represents JWT token. Refresh token is returned only by login and refresh actions
 type Token struct {
-	Value string `json:"token"`
+	Value        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
 }
 
-// Claims represents JWT token claims which are used in the app
+// Claims represents JWT token claims which are used in the app.
+// TokenID (jti) is used to revoke the token before it is expired,
+// tokens issued before the password change are rejected using IssuedAt.
 type Claims struct {
 	User
+	TokenID   string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
 }
 
-// GenerateAuthToken returns auth token for the user
+// RefreshToken is exchanged for the new pair of tokens. Only hash of the token is stored,
+// plain value is sent to the client once. Tokens issued by rotation share the family,
+// so the whole chain is revoked when a used token is presented again.
+type RefreshToken struct {
+	ID        string
+	UserID    string
+	FamilyID  string
+	Hash      string
+	ExpiresAt time.Time
+}
+
+func randomHex(size int) (string, error) {
+	bytes := make([]byte, size)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+// NewRefreshToken generates plain refresh token for the user and its record which should be stored
+func NewRefreshToken(userID string) (string, RefreshToken, error) {
+	plain, err := randomHex(32)
+
+	if err != nil {
+		return "", RefreshToken{}, err
+	}
+
+	ttl := configuration.GetConfiguration().RefreshTokenTTL
+
+	return plain, RefreshToken{
+		UserID:    userID,
+		Hash:      HashRefreshToken(plain),
+		ExpiresAt: time.Now().Add(time.Second * time.Duration(ttl)),
+	}, nil
+}
+
+// PasswordResetToken is sent to the user to set a new password. It could be used once
+// before it is expired, only hash of the token is stored.
+type PasswordResetToken struct {
+	ID        string
+	UserID    string
+	Hash      string
+	ExpiresAt time.Time
+}
+
+// NewPasswordResetToken generates plain reset token for the user and its record which should be stored
+func NewPasswordResetToken(userID string) (string, PasswordResetToken, error) {
+	plain, err := randomHex(32)
+
+	if err != nil {
+		return "", PasswordResetToken{}, err
+	}
+
+	ttl := configuration.GetConfiguration().PasswordResetTTL
+
+	return plain, PasswordResetToken{
+		UserID:    userID,
+		Hash:      HashPasswordResetToken(plain),
+		ExpiresAt: time.Now().Add(time.Second * time.Duration(ttl)),
+	}, nil
+}
+
+// HashPasswordResetToken returns hash of the plain reset token which is stored
+func HashPasswordResetToken(plain string) string {
+	return hashSecret(plain)
+}
+
+// hashSecret returns hash of the random secret (refresh token, API key).
+// Secrets are random, so fast hash is enough to protect them if the table leaks.
+func hashSecret(plain string) string {
+	hash := sha256.Sum256([]byte(plain))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// HashRefreshToken returns hash of the plain refresh token which is stored
+func HashRefreshToken(plain string) string {
+	return hashSecret(plain)
+}
+
+// NewClaimsFromContext returns claims of the access token which is used for the request
+func NewClaimsFromContext(ctx context.Context) (*Claims, error) {
+	claims, ok := ctx.Value("claims").(*Claims)
+
+	if !ok {
+		return nil, errors.New("Claims are not specified in the context")
+	}
+
+	return claims, nil
+}
+
+// GenerateAuthToken returns auth token for the user. Role is added to the claims,
+// so it is checked without database queries until the token is expired.
 func GenerateAuthToken(user User) (Token, error) {
 	ttl := configuration.GetConfiguration().TokenTTL
+	tokenID, err := randomHex(16)
+
+	if err != nil {
+		return Token{}, err
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user": struct {
 			Login string `json:"login"`
 			ID    string `json:"id"`
+			Role  string `json:"role,omitempty"`
 		}{
 			Login: user.Login,
 			ID:    user.ID,
+			Role:  user.Role,
 		},
 		"exp": time.Now().Add(time.Second * time.Duration(ttl)).Unix(),
+		"iat": time.Now().Unix(),
+		"jti": tokenID,
 	})
 	secret := configuration.GetConfiguration().TokenSecret
 	tokenString, err := token.SignedString([]byte(secret))
@@ -90,5 +204,16 @@ func (t *Token) GetClaims() (*Claims, error) {
 		return nil, errors.New("User claim has unknown type")
 	}
 
-	return &Claims{User: user}, nil
+	claims := Claims{User: user}
+	claims.TokenID, _ = rawClaims["jti"].(string)
+
+	if exp, ok := rawClaims["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	if iat, ok := rawClaims["iat"].(float64); ok {
+		claims.IssuedAt = time.Unix(int64(iat), 0)
+	}
+
+	return &claims, nil
 }
@@ -0,0 +1,60 @@
+package models_test
+
+import (
+	"context"
+	"shortener/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthTokenClaims(t *testing.T) {
+	user := models.User{ID: "1", Login: "batman", Role: models.RoleAdmin}
+
+	first, err := models.GenerateAuthToken(user)
+	require.Nil(t, err)
+	second, err := models.GenerateAuthToken(user)
+	require.Nil(t, err)
+
+	firstClaims, err := first.GetClaims()
+	require.Nil(t, err)
+	secondClaims, err := second.GetClaims()
+	require.Nil(t, err)
+
+	assert.Equal(t, user.ID, firstClaims.ID)
+	assert.Equal(t, user.Login, firstClaims.Login)
+	assert.Equal(t, user.Role, firstClaims.Role)
+	assert.NotEmpty(t, firstClaims.TokenID)
+	assert.NotEqual(t, firstClaims.TokenID, secondClaims.TokenID, "every token should have unique id")
+	assert.False(t, firstClaims.ExpiresAt.IsZero())
+
+	ctx := context.WithValue(context.Background(), "claims", firstClaims)
+	fromContext, err := models.NewClaimsFromContext(ctx)
+
+	require.Nil(t, err)
+	assert.Equal(t, firstClaims, fromContext)
+}
+
+func TestRefreshToken(t *testing.T) {
+	plain, token, err := models.NewRefreshToken("1")
+
+	require.Nil(t, err)
+	assert.Equal(t, "1", token.UserID)
+	assert.Equal(t, models.HashRefreshToken(plain), token.Hash)
+	assert.NotEqual(t, plain, token.Hash, "plain token should not be stored")
+	assert.True(t, token.ExpiresAt.After(time.Now()))
+}
+
+func TestPasswordResetToken(t *testing.T) {
+	plain, token, err := models.NewPasswordResetToken("1")
+
+	require.Nil(t, err)
+	assert.Equal(t, "1", token.UserID)
+	assert.Equal(t, models.HashPasswordResetToken(plain), token.Hash)
+	assert.True(t, token.ExpiresAt.After(time.Now()))
+
+	assert.Error(t, models.ValidateNewPassword("short"))
+	assert.Nil(t, models.ValidateNewPassword("long enough"))
+}
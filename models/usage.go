@@ -1,8 +1,13 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"shortener/configuration"
+	"shortener/logger"
+	"strings"
 	"time"
 )
 
@@ -11,6 +16,81 @@ type Usage struct {
 	ID      string    `json:"id"`
 	Created time.Time `json:"created"`
 	UrlID   string    `json:"urlId,omitempty"`
+	Meta    UsageMeta `json:"meta"`
+}
+
+// UsageMeta represents information about the click which is stored in the meta column
+type UsageMeta struct {
+	AcceptLanguage  string `json:"acceptLanguage,omitempty"`
+	Browser         string `json:"browser,omitempty"`
+	Country         string `json:"country,omitempty"`
+	IP              string `json:"ip,omitempty"`
+	OperatingSystem string `json:"os,omitempty"`
+	Query           string `json:"query,omitempty"`
+	Referrer        string `json:"referrer,omitempty"`
+	// RequestID ties the usage to the log entries of the redirect
+	RequestID string `json:"requestId,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// privateQueryParameters are not stored in the usages
+var privateQueryParameters = []string{"password"}
+
+// NewUsageFromRequest creates usage of the link using request headers.
+// Client IP should be resolved by the caller because it depends on the trusted proxies.
+// Country is taken from the header set by CDN or proxy (COUNTRY_HEADER).
+func NewUsageFromRequest(r *http.Request, linkID string, clientIP string) Usage {
+	query := r.URL.Query()
+
+	for _, parameter := range privateQueryParameters {
+		query.Del(parameter)
+	}
+
+	browser, operatingSystem := ParseUserAgent(r.UserAgent())
+	var country string
+
+	if header := configuration.GetConfiguration().CountryHeader; header != "" {
+		country = strings.ToUpper(r.Header.Get(header))
+	}
+
+	return Usage{
+		Created: time.Now(),
+		UrlID:   linkID,
+		Meta: UsageMeta{
+			AcceptLanguage:  r.Header.Get("Accept-Language"),
+			Browser:         browser,
+			Country:         country,
+			IP:              clientIP,
+			OperatingSystem: operatingSystem,
+			Query:           query.Encode(),
+			Referrer:        r.Referer(),
+			RequestID:       logger.RequestID(r.Context()),
+			UserAgent:       r.UserAgent(),
+		},
+	}
+}
+
+// Value converts meta to the database value. String is returned
+// because byte slices are sent as bytea by the driver.
+func (m UsageMeta) Value() (driver.Value, error) {
+	data, err := json.Marshal(m)
+
+	return string(data), err
+}
+
+// Scan reads meta from the database value
+func (m *UsageMeta) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case nil:
+		*m = UsageMeta{}
+		return nil
+	case []byte:
+		return json.Unmarshal(data, m)
+	case string:
+		return json.Unmarshal([]byte(data), m)
+	default:
+		return errors.New("Unknown type of usage meta")
+	}
 }
 
 func (u *Usage) populate(r *http.Request) error {
@@ -5,17 +5,39 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/mail"
 	"time"
 )
 
+// Roles of the users. Support role has read-only access to the administration routes
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+// RoleGuest belongs to the guests of the anonymous visitors. Guests do not have an account,
+// their links are transferred to the account on signup or login
+const RoleGuest = "guest"
+
+// roleLevels orders roles, so higher role has all permissions of the lower ones
+var roleLevels = map[string]int{
+	RoleUser:    0,
+	RoleSupport: 1,
+	RoleAdmin:   2,
+}
+
 // User type represents user
 type User struct {
-	Login      string    `json:"login"`
-	Password   string    `json:"password,omitempty"`
-	ID         string    `json:"id"`
-	Created    time.Time `json:"created"`
-	LinksCount int64     `json:"linksCount"`
-	Links      []*Link   `json:"links,omitempty"`
+	Login      string     `json:"login"`
+	Password   string     `json:"password,omitempty"`
+	ID         string     `json:"id"`
+	Role       string     `json:"role,omitempty"`
+	Plan       string     `json:"plan,omitempty"`
+	Created    time.Time  `json:"created"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+	LinksCount int64      `json:"linksCount"`
+	Links      []*Link    `json:"links,omitempty"`
 }
 
 // NewUserFromRequest creates new user fields from request.
@@ -42,6 +64,63 @@ func NewUserFromContext(ctx context.Context) (*User, error) {
 	return user, nil
 }
 
+const maxLoginLength = 256
+const minPasswordLength = 8
+
+// bcrypt ignores bytes after 72
+const maxPasswordLength = 72
+
+// ValidateLogin checks that login could be saved. FieldError is returned
+func ValidateLogin(login string) error {
+	if login == "" || len(login) > maxLoginLength {
+		return FieldError{"login", "invalid_length", "Login should be between 1 and 256 characters"}
+	}
+
+	return nil
+}
+
+// ValidateNewPassword checks length of the password which is set by the user. FieldError is returned
+func ValidateNewPassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return FieldError{"password", "invalid_length", "Password should be between 8 and 72 characters"}
+	}
+
+	return nil
+}
+
+// IsEmailAddress reports whether login is a plain email address, so notifications could be sent to it
+func IsEmailAddress(login string) bool {
+	address, err := mail.ParseAddress(login)
+
+	return err == nil && address.Address == login
+}
+
+// ValidateRole checks that role is known. FieldError is returned
+func ValidateRole(role string) error {
+	if _, ok := roleLevels[role]; !ok {
+		return FieldError{"role", "unknown_role", "Role should be one of user, support or admin"}
+	}
+
+	return nil
+}
+
+// HasRole reports whether user has the role or a higher one. Users without role
+// (e.g. tokens issued before roles were introduced) are regular users.
+func (user *User) HasRole(role string) bool {
+	required, ok := roleLevels[role]
+
+	if !ok {
+		return false
+	}
+
+	return roleLevels[user.Role] >= required
+}
+
+// IsDisabled reports whether account is disabled by the administrator
+func (user *User) IsDisabled() bool {
+	return user.DisabledAt != nil
+}
+
 // CleanPrivateFields removes private fields (e.g. Password) from user object
 func (user *User) CleanPrivateFields() {
 	user.Password = ""
@@ -0,0 +1,34 @@
+package models_test
+
+import (
+	"shortener/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserHasRole(t *testing.T) {
+	admin := models.User{Role: models.RoleAdmin}
+	support := models.User{Role: models.RoleSupport}
+	legacy := models.User{}
+
+	assert.True(t, admin.HasRole(models.RoleSupport), "admin should have permissions of support")
+	assert.True(t, support.HasRole(models.RoleSupport))
+	assert.False(t, support.HasRole(models.RoleAdmin))
+	assert.True(t, legacy.HasRole(models.RoleUser), "users without role are regular users")
+	assert.False(t, legacy.HasRole(models.RoleSupport))
+	assert.False(t, admin.HasRole("root"), "unknown roles should not be granted")
+}
+
+func TestValidateRole(t *testing.T) {
+	assert.Nil(t, models.ValidateRole(models.RoleSupport))
+	assert.IsType(t, models.FieldError{}, models.ValidateRole("root"))
+	assert.IsType(t, models.FieldError{}, models.ValidateRole(""))
+}
+
+func TestIsEmailAddress(t *testing.T) {
+	assert.True(t, models.IsEmailAddress("batman@example.com"))
+	assert.False(t, models.IsEmailAddress("batman"))
+	assert.False(t, models.IsEmailAddress("Batman <batman@example.com>"), "only plain addresses should be accepted")
+	assert.False(t, models.IsEmailAddress("batman@example.com, robin@example.com"))
+}
@@ -0,0 +1,64 @@
+package models
+
+import "strings"
+
+type userAgentRule struct {
+	name    string
+	include []string
+	exclude []string
+}
+
+// rules are checked in order, so more specific rules should be placed first
+var browserRules = []userAgentRule{
+	{"Bot", []string{"bot", "spider", "crawl"}, nil},
+	{"Edge", []string{"edg/", "edge/"}, nil},
+	{"Opera", []string{"opr/", "opera"}, nil},
+	{"Chrome", []string{"chrome/", "crios/"}, []string{"chromium"}},
+	{"Firefox", []string{"firefox/", "fxios/"}, nil},
+	{"Safari", []string{"safari/"}, nil},
+	{"Internet Explorer", []string{"msie", "trident/"}, nil},
+	{"curl", []string{"curl/"}, nil},
+}
+
+var osRules = []userAgentRule{
+	{"Windows", []string{"windows"}, nil},
+	{"iOS", []string{"iphone", "ipad", "ipod"}, nil},
+	{"Android", []string{"android"}, nil},
+	{"macOS", []string{"mac os x", "macintosh"}, nil},
+	{"Chrome OS", []string{"cros "}, nil},
+	{"Linux", []string{"linux"}, nil},
+}
+
+// UnknownUserAgentValue is used when browser or operating system could not be detected
+const UnknownUserAgentValue = "Other"
+
+func matchUserAgent(userAgent string, rules []userAgentRule) string {
+	for _, rule := range rules {
+		if containsAny(userAgent, rule.include) && !containsAny(userAgent, rule.exclude) {
+			return rule.name
+		}
+	}
+
+	return UnknownUserAgentValue
+}
+
+func containsAny(value string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(value, substring) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseUserAgent returns browser and operating system names from the User-Agent header
+func ParseUserAgent(userAgent string) (string, string) {
+	if userAgent == "" {
+		return UnknownUserAgentValue, UnknownUserAgentValue
+	}
+
+	userAgent = strings.ToLower(userAgent)
+
+	return matchUserAgent(userAgent, browserRules), matchUserAgent(userAgent, osRules)
+}
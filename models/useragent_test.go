@@ -0,0 +1,33 @@
+package models_test
+
+import (
+	"shortener/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		browser   string
+		os        string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/73.0.3683.86 Safari/537.36", "Chrome", "Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1 Safari/605.1.15", "Safari", "macOS"},
+		{"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:66.0) Gecko/20100101 Firefox/66.0", "Firefox", "Linux"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 12_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1 Mobile/15E148 Safari/604.1", "Safari", "iOS"},
+		{"Mozilla/5.0 (Linux; Android 9; Pixel 3) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/73.0.3683.90 Mobile Safari/537.36", "Chrome", "Android"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/64.0.3282.140 Safari/537.36 Edge/18.17763", "Edge", "Windows"},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Bot", models.UnknownUserAgentValue},
+		{"curl/7.54.0", "curl", models.UnknownUserAgentValue},
+		{"", models.UnknownUserAgentValue, models.UnknownUserAgentValue},
+	}
+
+	for _, test := range tests {
+		browser, os := models.ParseUserAgent(test.userAgent)
+
+		assert.Equal(t, test.browser, browser, test.userAgent)
+		assert.Equal(t, test.os, os, test.userAgent)
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"shortener/configuration"
+	"time"
+)
+
+// Roles of the workspace members. Viewers read links and stats, editors also change links,
+// owners also manage members and the workspace itself
+const (
+	WorkspaceViewer = "viewer"
+	WorkspaceEditor = "editor"
+	WorkspaceOwner  = "owner"
+)
+
+// workspaceRoleLevels orders member roles, so higher role has all permissions of the lower ones
+var workspaceRoleLevels = map[string]int{
+	WorkspaceViewer: 1,
+	WorkspaceEditor: 2,
+	WorkspaceOwner:  3,
+}
+
+const maxWorkspaceNameLength = 256
+
+// Workspace is shared by the team. Links of the workspace are managed by its members.
+// Role is a role of the current user, members are returned only for a single workspace
+type Workspace struct {
+	ID      string             `json:"id"`
+	Name    string             `json:"name"`
+	Created time.Time          `json:"created"`
+	Role    string             `json:"role,omitempty"`
+	Members []*WorkspaceMember `json:"members,omitempty"`
+}
+
+// WorkspaceMember represents membership of the user in the workspace
+type WorkspaceMember struct {
+	WorkspaceID string    `json:"workspaceId"`
+	UserID      string    `json:"userId"`
+	Login       string    `json:"login,omitempty"`
+	Role        string    `json:"role"`
+	Created     time.Time `json:"created"`
+}
+
+// WorkspaceInvitation is accepted (or declined) by the invited user, who becomes a member with the role
+type WorkspaceInvitation struct {
+	ID            string    `json:"id"`
+	WorkspaceID   string    `json:"workspaceId"`
+	WorkspaceName string    `json:"workspaceName,omitempty"`
+	UserID        string    `json:"userId"`
+	InvitedBy     string    `json:"invitedBy,omitempty"`
+	Role          string    `json:"role"`
+	Created       time.Time `json:"created"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+}
+
+// Populate reads name of the workspace
+func (workspace *Workspace) Populate(r *http.Request) error {
+	var request struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return err
+	}
+
+	workspace.Name = request.Name
+
+	return nil
+}
+
+// Validate checks user provided fields of the workspace. FieldError is returned
+func (workspace *Workspace) Validate() error {
+	if workspace.Name == "" || len(workspace.Name) > maxWorkspaceNameLength {
+		return FieldError{"name", "invalid_length", "Name should be between 1 and 256 characters"}
+	}
+
+	return nil
+}
+
+// ValidateWorkspaceRole checks that member role is known. FieldError is returned
+func ValidateWorkspaceRole(role string) error {
+	if _, ok := workspaceRoleLevels[role]; !ok {
+		return FieldError{"role", "unknown_role", "Role should be one of owner, editor or viewer"}
+	}
+
+	return nil
+}
+
+// HasRole reports whether member has the role or a higher one
+func (member *WorkspaceMember) HasRole(role string) bool {
+	required, ok := workspaceRoleLevels[role]
+
+	return ok && workspaceRoleLevels[member.Role] >= required
+}
+
+// NewWorkspaceInvitation creates invitation of the user which expires according to the configuration
+func NewWorkspaceInvitation(workspaceID string, userID string, role string) WorkspaceInvitation {
+	ttl := configuration.GetConfiguration().WorkspaceInvitationTTL
+
+	return WorkspaceInvitation{
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+		Role:        role,
+		ExpiresAt:   time.Now().Add(time.Second * time.Duration(ttl)),
+	}
+}
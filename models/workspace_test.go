@@ -0,0 +1,37 @@
+package models_test
+
+import (
+	"shortener/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspaceMemberHasRole(t *testing.T) {
+	owner := models.WorkspaceMember{Role: models.WorkspaceOwner}
+	viewer := models.WorkspaceMember{Role: models.WorkspaceViewer}
+
+	assert.True(t, owner.HasRole(models.WorkspaceEditor), "owner should have permissions of editor")
+	assert.True(t, viewer.HasRole(models.WorkspaceViewer))
+	assert.False(t, viewer.HasRole(models.WorkspaceEditor))
+	assert.False(t, (&models.WorkspaceMember{}).HasRole(models.WorkspaceViewer), "unknown role should not be granted")
+}
+
+func TestWorkspaceValidate(t *testing.T) {
+	assert.Nil(t, (&models.Workspace{Name: "Marketing"}).Validate())
+	assert.IsType(t, models.FieldError{}, (&models.Workspace{}).Validate())
+	assert.IsType(t, models.FieldError{}, (&models.Workspace{Name: strings.Repeat("a", 257)}).Validate())
+
+	assert.Nil(t, models.ValidateWorkspaceRole(models.WorkspaceEditor))
+	assert.IsType(t, models.FieldError{}, models.ValidateWorkspaceRole("admin"))
+}
+
+func TestNewWorkspaceInvitation(t *testing.T) {
+	invitation := models.NewWorkspaceInvitation("1", "2", models.WorkspaceViewer)
+
+	assert.Equal(t, "1", invitation.WorkspaceID)
+	assert.Equal(t, "2", invitation.UserID)
+	assert.True(t, invitation.ExpiresAt.After(time.Now()))
+}
@@ -0,0 +1,153 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"shortener/configuration"
+	"shortener/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifiers which could be configured by NOTIFIER
+const (
+	LogNotifierName  = "log"
+	FileNotifierName = "file"
+	SMTPNotifierName = "smtp"
+)
+
+// ErrNotConfigured is returned when NOTIFIER is empty, features which notify users should be disabled
+var ErrNotConfigured = errors.New("Notifier is not configured")
+
+// Message is sent to the user
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to the users
+type Notifier interface {
+	Notify(ctx context.Context, message Message) error
+}
+
+// LogNotifier writes messages to the application log. It should be used only for local development,
+// because messages (e.g. password reset tokens) are visible to everyone who reads the log
+type LogNotifier struct{}
+
+// FileNotifier appends messages to the file. It should be used only for local development
+type FileNotifier struct {
+	mutex sync.Mutex
+	path  string
+}
+
+// SMTPNotifier sends messages by email
+type SMTPNotifier struct {
+	address string
+	from    string
+	auth    smtp.Auth
+}
+
+// NewLogNotifier creates LogNotifier
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// NewFileNotifier creates FileNotifier which writes to the path
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if path == "" {
+		return nil, errors.New("Path of the notifier file is not specified")
+	}
+
+	return &FileNotifier{path: path}, nil
+}
+
+// NewSMTPNotifier creates SMTPNotifier. Authentication is used when username is not empty
+func NewSMTPNotifier(address string, username string, password string, from string) (*SMTPNotifier, error) {
+	if address == "" || from == "" {
+		return nil, errors.New("SMTP address and sender should be specified")
+	}
+
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	notifier := SMTPNotifier{address: address, from: from}
+
+	if username != "" {
+		notifier.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &notifier, nil
+}
+
+// NewNotifierFromConfiguration creates notifier using application configuration
+func NewNotifierFromConfiguration() (Notifier, error) {
+	config := configuration.GetConfiguration()
+
+	switch config.Notifier {
+	case "":
+		return nil, ErrNotConfigured
+	case LogNotifierName:
+		logger.Log.Warn("log notifier writes messages to the log, it should be used only for local development")
+		return NewLogNotifier(), nil
+	case FileNotifierName:
+		return NewFileNotifier(config.NotifierFile)
+	case SMTPNotifierName:
+		return NewSMTPNotifier(config.SMTPAddress, config.SMTPUsername, config.SMTPPassword, config.SMTPFrom)
+	default:
+		return nil, errors.New("Unknown notifier " + config.Notifier)
+	}
+}
+
+// Notify writes message to the log
+func (n *LogNotifier) Notify(ctx context.Context, message Message) error {
+	logger.FromContext(ctx).
+		WithField("to", message.To).
+		WithField("subject", message.Subject).
+		WithField("body", message.Body).
+		Info("notification")
+
+	return nil
+}
+
+// Notify appends message to the file
+func (n *FileNotifier) Notify(ctx context.Context, message Message) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), message.To, message.Subject, message.Body)
+
+	return err
+}
+
+// Notify sends message by email. Recipient should be an email address
+func (n *SMTPNotifier) Notify(ctx context.Context, message Message) error {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return errors.New("Message headers should not contain line breaks")
+	}
+
+	body := "From: " + n.from + "\r\n" +
+		"To: " + message.To + "\r\n" +
+		"Subject: " + message.Subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		message.Body
+
+	return smtp.SendMail(n.address, n.auth, n.from, []string{message.To}, []byte(body))
+}
@@ -0,0 +1,44 @@
+package notifier_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"shortener/notifier"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notifier")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "messages.txt")
+	n, err := notifier.NewFileNotifier(path)
+	require.Nil(t, err)
+
+	require.Nil(t, n.Notify(context.Background(), notifier.Message{To: "batman@example.com", Subject: "First", Body: "token-1"}))
+	require.Nil(t, n.Notify(context.Background(), notifier.Message{To: "batman@example.com", Subject: "Second", Body: "token-2"}))
+
+	content, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+
+	assert.Contains(t, string(content), "To: batman@example.com")
+	assert.Contains(t, string(content), "token-1")
+	assert.Contains(t, string(content), "token-2")
+}
+
+func TestSMTPNotifierValidation(t *testing.T) {
+	_, err := notifier.NewSMTPNotifier("", "", "", "noreply@example.com")
+	assert.Error(t, err)
+
+	n, err := notifier.NewSMTPNotifier("localhost:25", "", "", "noreply@example.com")
+	require.Nil(t, err)
+
+	err = n.Notify(context.Background(), notifier.Message{To: "batman@example.com\r\nBcc: robin@example.com", Subject: "Hi"})
+	assert.Error(t, err, "header injection should be rejected")
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"shortener/models"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyRepository type represents repository to work with API keys
+type APIKeyRepository BaseRepository
+
+// APIKeyRepositoryInterface interface
+type APIKeyRepositoryInterface interface {
+	Authenticate(string) (*models.User, *models.APIKey, error)
+	AuthenticateWithContext(context.Context, string) (*models.User, *models.APIKey, error)
+	Create(models.APIKey) (*models.APIKey, error)
+	CreateWithContext(context.Context, models.APIKey) (*models.APIKey, error)
+	FindAllByUser(models.User) ([]*models.APIKey, error)
+	FindAllByUserWithContext(context.Context, models.User) ([]*models.APIKey, error)
+	Revoke(models.APIKey) error
+	RevokeWithContext(context.Context, models.APIKey) error
+}
+
+// apiKeyColumns are selected for every key, "k" is an alias of api_keys table
+const apiKeyColumns = "k.id, k.user_id, k.name, k.prefix, k.scopes, k.created, k.expires_at, k.last_used_at"
+
+// NewAPIKeyRepository creates API keys repository
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepositoryInterface {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+// scanAPIKey reads columns listed in apiKeyColumns and additional columns into the key
+func scanAPIKey(row scanner, key *models.APIKey, additional ...interface{}) error {
+	var expiresAt, lastUsedAt pq.NullTime
+	var scopes pq.StringArray
+
+	dest := []interface{}{
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&key.Created,
+		&expiresAt,
+		&lastUsedAt,
+	}
+
+	if err := row.Scan(append(dest, additional...)...); err != nil {
+		return err
+	}
+
+	key.Scopes = []string(scopes)
+	key.ExpiresAt = nil
+	key.LastUsedAt = nil
+
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return nil
+}
+
+// Authenticate returns owner of the key with the given hash
+func (repository *APIKeyRepository) Authenticate(hash string) (*models.User, *models.APIKey, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.AuthenticateWithContext(ctx, hash)
+}
+
+// AuthenticateWithContext returns owner of the active key with the given hash and records
+// the time when the key is used. ErrInvalidAPIKey is returned for unknown, revoked and expired keys
+// and for the keys of the disabled users.
+func (repository *APIKeyRepository) AuthenticateWithContext(ctx context.Context, hash string) (*models.User, *models.APIKey, error) {
+	var user models.User
+	var key models.APIKey
+
+	statement := `
+		update api_keys k
+		set last_used_at = now()
+		from users u
+		where k.key_hash = $1
+		and u.id = k.user_id
+		and u.disabled_at is null
+		and k.revoked_at is null
+		and (k.expires_at is null or k.expires_at > now())
+		returning ` + apiKeyColumns + `, u.login, u.role
+		`
+	err := scanAPIKey(repository.db.QueryRowContext(ctx, statement, hash), &key, &user.Login, &user.Role)
+
+	if err != nil {
+		return nil, nil, notFoundAs(err, ErrInvalidAPIKey)
+	}
+
+	user.ID = key.UserID
+
+	return &user, &key, nil
+}
+
+// Create saves API key
+func (repository *APIKeyRepository) Create(key models.APIKey) (*models.APIKey, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateWithContext(ctx, key)
+}
+
+// CreateWithContext saves API key. Key should be already generated, only its hash is stored
+func (repository *APIKeyRepository) CreateWithContext(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	statement := `
+		insert into api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		values ($1, $2, $3, $4, $5, $6)
+		returning id, created
+		`
+	expiresAt := pq.NullTime{Valid: key.ExpiresAt != nil}
+
+	if expiresAt.Valid {
+		expiresAt.Time = *key.ExpiresAt
+	}
+
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	err := repository.db.QueryRowContext(ctx, statement, key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), expiresAt).Scan(&key.ID, &key.Created)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// FindAllByUser returns active keys of the user
+func (repository *APIKeyRepository) FindAllByUser(user models.User) ([]*models.APIKey, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindAllByUserWithContext(ctx, user)
+}
+
+// FindAllByUserWithContext returns keys of the user which are not revoked. Expired keys are returned as well
+func (repository *APIKeyRepository) FindAllByUserWithContext(ctx context.Context, user models.User) ([]*models.APIKey, error) {
+	statement := `
+		select ` + apiKeyColumns + `
+		from api_keys k
+		where k.user_id = $1 and k.revoked_at is null
+		order by k.created desc
+		`
+	rows, err := repository.db.QueryContext(ctx, statement, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := make([]*models.APIKey, 0)
+
+	for rows.Next() {
+		var key models.APIKey
+
+		if err = scanAPIKey(rows, &key); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Revoke disables API key
+func (repository *APIKeyRepository) Revoke(key models.APIKey) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.RevokeWithContext(ctx, key)
+}
+
+// RevokeWithContext disables API key of the user. ErrAPIKeyNotFound is returned
+// when the key does not exist, belongs to another user or is already revoked.
+func (repository *APIKeyRepository) RevokeWithContext(ctx context.Context, key models.APIKey) error {
+	if !uuidPattern.MatchString(key.ID) {
+		return ErrAPIKeyNotFound
+	}
+
+	statement := "update api_keys set revoked_at = now() where id = $1 and user_id = $2 and revoked_at is null"
+	result, err := repository.db.ExecContext(ctx, statement, key.ID, key.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
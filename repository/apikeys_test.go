@@ -0,0 +1,63 @@
+package repository_test
+
+import (
+	"shortener/models"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teris-io/shortid"
+)
+
+func TestAPIKeysPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip tests for API keys repository for unit tests")
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	users := repository.NewUserRepository(suite.GetDB())
+	keys := repository.NewAPIKeyRepository(suite.GetDB())
+
+	login, _ := shortid.Generate()
+	user, err := users.Create(models.User{Login: login, Password: "password"})
+	require.Nil(t, err)
+	defer users.Delete(*user)
+
+	key := models.APIKey{UserID: user.ID, Name: "ci", Scopes: []string{models.ScopeLinksWrite}}
+	require.Nil(t, key.Generate())
+
+	created, err := keys.Create(key)
+	require.Nil(t, err)
+
+	t.Run("should authenticate by the key hash", func(t *testing.T) {
+		owner, found, err := keys.Authenticate(models.HashAPIKey(key.Key))
+
+		require.Nil(t, err)
+		assert.Equal(t, user.ID, owner.ID)
+		assert.Equal(t, user.Login, owner.Login)
+		assert.Equal(t, []string{models.ScopeLinksWrite}, found.Scopes)
+		assert.NotNil(t, found.LastUsedAt, "last used time should be recorded")
+	})
+
+	t.Run("should list keys of the user", func(t *testing.T) {
+		found, err := keys.FindAllByUser(*user)
+
+		require.Nil(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, created.ID, found[0].ID)
+		assert.Empty(t, found[0].Key, "plain key should not be returned")
+	})
+
+	t.Run("should revoke the key", func(t *testing.T) {
+		assert.Equal(t, repository.ErrAPIKeyNotFound, keys.Revoke(models.APIKey{ID: created.ID, UserID: "00000000-0000-0000-0000-000000000000"}))
+		require.Nil(t, keys.Revoke(models.APIKey{ID: created.ID, UserID: user.ID}))
+
+		_, _, err := keys.Authenticate(models.HashAPIKey(key.Key))
+		assert.Equal(t, repository.ErrInvalidAPIKey, err)
+	})
+}
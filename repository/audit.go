@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"shortener/models"
+	"shortener/models/options"
+)
+
+// AuditRepository type represents repository to work with audit log of the administrators
+type AuditRepository BaseRepository
+
+// AuditRepositoryInterface interface
+type AuditRepositoryInterface interface {
+	Create(models.AuditEntry) (*models.AuditEntry, error)
+	CreateWithContext(context.Context, models.AuditEntry) (*models.AuditEntry, error)
+	FindAll(options.Options) ([]*models.AuditEntry, error)
+	FindAllWithContext(context.Context, options.Options) ([]*models.AuditEntry, error)
+}
+
+// NewAuditRepository creates audit log repository
+func NewAuditRepository(db *sql.DB) AuditRepositoryInterface {
+	return &AuditRepository{
+		db: db,
+	}
+}
+
+// Create saves new entry to the audit log
+func (repository *AuditRepository) Create(entry models.AuditEntry) (*models.AuditEntry, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateWithContext(ctx, entry)
+}
+
+// CreateWithContext saves new entry to the audit log
+func (repository *AuditRepository) CreateWithContext(ctx context.Context, entry models.AuditEntry) (*models.AuditEntry, error) {
+	var details sql.NullString
+
+	if entry.Details != nil {
+		data, err := json.Marshal(entry.Details)
+
+		if err != nil {
+			return nil, err
+		}
+
+		details = sql.NullString{String: string(data), Valid: true}
+	}
+
+	statement := `
+		insert into audit_log (actor_id, action, target_type, target_id, details)
+		values ($1, $2, $3, $4, $5)
+		returning id, created
+		`
+	err := repository.db.QueryRowContext(
+		ctx,
+		statement,
+		sql.NullString{String: entry.ActorID, Valid: entry.ActorID != ""},
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		details,
+	).Scan(&entry.ID, &entry.Created)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// FindAll returns entries of the audit log
+func (repository *AuditRepository) FindAll(opts options.Options) ([]*models.AuditEntry, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindAllWithContext(ctx, opts)
+}
+
+// FindAllWithContext returns entries of the audit log, recent entries go first
+func (repository *AuditRepository) FindAllWithContext(ctx context.Context, opts options.Options) ([]*models.AuditEntry, error) {
+	statement := `
+		select id, coalesce(actor_id::text, ''), action, target_type, target_id, details, created
+		from audit_log
+		order by created desc
+		limit $1
+		offset $2
+		`
+	rows, err := repository.db.QueryContext(ctx, statement, opts.Limit, opts.Offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		var details []byte
+
+		err = rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID, &details, &entry.Created)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if details != nil {
+			if err = json.Unmarshal(details, &entry.Details); err != nil {
+				return nil, err
+			}
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
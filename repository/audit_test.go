@@ -0,0 +1,52 @@
+package repository_test
+
+import (
+	"shortener/models"
+	"shortener/models/options"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teris-io/shortid"
+)
+
+func TestAuditPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip tests for audit log repository for unit tests")
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	users := repository.NewUserRepository(suite.GetDB())
+	audit := repository.NewAuditRepository(suite.GetDB())
+
+	login, _ := shortid.Generate()
+	admin, err := users.Create(models.User{Login: login, Password: "password"})
+	require.Nil(t, err)
+	defer users.Delete(*admin)
+
+	t.Run("should record action of the administrator", func(t *testing.T) {
+		created, err := audit.Create(models.AuditEntry{
+			ActorID:    admin.ID,
+			Action:     models.AuditDisableUser,
+			TargetType: models.AuditTargetUser,
+			TargetID:   admin.ID,
+			Details:    map[string]interface{}{"reason": "test"},
+		})
+
+		require.Nil(t, err)
+		assert.NotEmpty(t, created.ID)
+
+		entries, err := audit.FindAll(options.Options{Limit: 1})
+
+		require.Nil(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, created.ID, entries[0].ID)
+		assert.Equal(t, admin.ID, entries[0].ActorID)
+		assert.Equal(t, "test", entries[0].Details["reason"])
+	})
+}
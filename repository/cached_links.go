@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"shortener/cache"
+	"shortener/configuration"
+	"shortener/models"
+	"time"
+)
+
+// CachedLinkRepository decorates links repository with the cache of FindByID lookups.
+// Unknown ids are cached as well (negative caching). Cache is local for the process,
+// so changes made by other instances are visible after TTL.
+type CachedLinkRepository struct {
+	LinksRepositoryInterface
+	cache       *cache.LRU
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+// NewCachedLinkRepository wraps links repository with the cache using application configuration
+func NewCachedLinkRepository(links LinksRepositoryInterface) *CachedLinkRepository {
+	config := configuration.GetConfiguration()
+
+	return &CachedLinkRepository{
+		LinksRepositoryInterface: links,
+		cache:                    cache.NewLRU(config.LinkCacheSize),
+		ttl:                      time.Duration(config.LinkCacheTTL) * time.Second,
+		negativeTTL:              time.Duration(config.LinkCacheNegativeTTL) * time.Second,
+	}
+}
+
+// CacheStats returns hit/miss counters of the cache
+func (repository *CachedLinkRepository) CacheStats() cache.Stats {
+	return repository.cache.Stats()
+}
+
+// copyLink returns a copy which could be changed without affecting the cache
+func copyLink(link *models.Link) *models.Link {
+	copied := *link
+
+	if link.ExpiresAt != nil {
+		expiresAt := *link.ExpiresAt
+		copied.ExpiresAt = &expiresAt
+	}
+
+	return &copied
+}
+
+// LinkCacheInvalidator is implemented by the links repositories with cache.
+// Links changed by other repositories (e.g. transferred to another user) are passed to it.
+type LinkCacheInvalidator interface {
+	Invalidate(links ...models.Link)
+}
+
+// InvalidateLinks removes the links from the cache of the repository, repositories without cache are skipped
+func InvalidateLinks(linkRepository LinksRepositoryInterface, links []models.Link) {
+	if invalidator, ok := linkRepository.(LinkCacheInvalidator); ok {
+		invalidator.Invalidate(links...)
+	}
+}
+
+// Invalidate removes the links from the cache, so the next lookups read them from the database
+func (repository *CachedLinkRepository) Invalidate(links ...models.Link) {
+	for i := range links {
+		repository.invalidate(&links[i])
+	}
+}
+
+// invalidate removes all keys which could point to the links
+func (repository *CachedLinkRepository) invalidate(links ...*models.Link) {
+	for _, link := range links {
+		if link != nil {
+			repository.cache.Delete(link.ID, link.Code, link.Alias)
+		}
+	}
+}
+
+// ConsumeClick registers click for the link with limited clicks count
+func (repository *CachedLinkRepository) ConsumeClick(link models.Link) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.ConsumeClickWithContext(ctx, link)
+}
+
+// ConsumeClickWithContext registers click for the link with limited clicks count.
+// Link is removed from the cache when it is expired, so the next lookup returns its status.
+func (repository *CachedLinkRepository) ConsumeClickWithContext(ctx context.Context, link models.Link) (bool, error) {
+	ok, err := repository.LinksRepositoryInterface.ConsumeClickWithContext(ctx, link)
+
+	if err == nil && !ok {
+		repository.invalidate(&link)
+	}
+
+	return ok, err
+}
+
+// Create saves user's link to the database
+func (repository *CachedLinkRepository) Create(link models.Link) (*models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateWithContext(ctx, link)
+}
+
+// CreateWithContext saves user's link to the database and removes negative cache of its code and alias
+func (repository *CachedLinkRepository) CreateWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
+	created, err := repository.LinksRepositoryInterface.CreateWithContext(ctx, link)
+
+	repository.invalidate(created)
+
+	return created, err
+}
+
+// Delete removes the link
+func (repository *CachedLinkRepository) Delete(link models.Link) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.DeleteWithContext(ctx, link)
+}
+
+// DeleteWithContext removes the link and its cache
+func (repository *CachedLinkRepository) DeleteWithContext(ctx context.Context, link models.Link) error {
+	stored, _ := repository.LinksRepositoryInterface.FindByIDWithContext(ctx, models.Link{ID: link.ID})
+	err := repository.LinksRepositoryInterface.DeleteWithContext(ctx, link)
+
+	repository.invalidate(&link, stored)
+
+	return err
+}
+
+// FindByID returns link by link id
+func (repository *CachedLinkRepository) FindByID(link models.Link) (*models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindByIDWithContext(ctx, link)
+}
+
+// FindByIDWithContext returns link by link id from the cache or from the decorated repository
+func (repository *CachedLinkRepository) FindByIDWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
+	key := link.ID
+
+	if value, ok := repository.cache.Get(key); ok {
+		cached, _ := value.(*models.Link)
+
+		if cached == nil {
+			return &link, ErrLinkNotFound
+		}
+
+		found := copyLink(cached)
+		found.UpdateStatus(time.Now())
+
+		return found, nil
+	}
+
+	found, err := repository.LinksRepositoryInterface.FindByIDWithContext(ctx, link)
+
+	switch err {
+	case nil:
+		repository.cache.Set(key, copyLink(found), repository.ttl)
+	case ErrLinkNotFound:
+		repository.cache.Set(key, (*models.Link)(nil), repository.negativeTTL)
+	}
+
+	return found, err
+}
+
+// Update saves mutable fields of the link
+func (repository *CachedLinkRepository) Update(link models.Link) (*models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.UpdateWithContext(ctx, link)
+}
+
+// UpdateWithContext saves mutable fields of the link. Old and new keys of the link are removed from the cache.
+func (repository *CachedLinkRepository) UpdateWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
+	stored, _ := repository.LinksRepositoryInterface.FindByIDWithContext(ctx, models.Link{ID: link.ID})
+	updated, err := repository.LinksRepositoryInterface.UpdateWithContext(ctx, link)
+
+	repository.invalidate(&link, stored, updated)
+
+	return updated, err
+}
@@ -0,0 +1,134 @@
+package repository_test
+
+import (
+	"context"
+	"shortener/models"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLinkRepository struct {
+	repository.LinksRepositoryInterface
+	links   map[string]models.Link
+	lookups int
+}
+
+func (r *fakeLinkRepository) FindByIDWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
+	r.lookups++
+
+	for _, stored := range r.links {
+		if stored.ID == link.ID || stored.Code == link.ID || stored.Alias == link.ID {
+			return &stored, nil
+		}
+	}
+
+	return &link, repository.ErrLinkNotFound
+}
+
+func (r *fakeLinkRepository) CreateWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
+	r.links[link.ID] = link
+
+	return &link, nil
+}
+
+func (r *fakeLinkRepository) UpdateWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
+	r.links[link.ID] = link
+
+	return &link, nil
+}
+
+func (r *fakeLinkRepository) DeleteWithContext(ctx context.Context, link models.Link) error {
+	delete(r.links, link.ID)
+
+	return nil
+}
+
+func TestCachedLinkRepository(t *testing.T) {
+	fake := &fakeLinkRepository{links: map[string]models.Link{
+		"1": {ID: "1", Code: "abc", URL: "https://example.com"},
+	}}
+	cached := repository.NewCachedLinkRepository(fake)
+
+	t.Run("should cache found links", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			link, err := cached.FindByID(models.Link{ID: "abc"})
+
+			require.Nil(t, err)
+			assert.Equal(t, "https://example.com", link.URL)
+		}
+
+		assert.Equal(t, 1, fake.lookups, "decorated repository should be called once")
+	})
+
+	t.Run("should not share cached objects", func(t *testing.T) {
+		link, _ := cached.FindByID(models.Link{ID: "abc"})
+		link.URL = "changed"
+
+		link, _ = cached.FindByID(models.Link{ID: "abc"})
+		assert.Equal(t, "https://example.com", link.URL)
+	})
+
+	t.Run("should cache unknown ids", func(t *testing.T) {
+		fake.lookups = 0
+
+		for i := 0; i < 3; i++ {
+			_, err := cached.FindByID(models.Link{ID: "spring-sale"})
+
+			assert.Equal(t, repository.ErrLinkNotFound, err)
+		}
+
+		assert.Equal(t, 1, fake.lookups, "decorated repository should be called once")
+	})
+
+	t.Run("should invalidate negative cache on create", func(t *testing.T) {
+		_, err := cached.Create(models.Link{ID: "2", Code: "def", Alias: "spring-sale", URL: "https://example.org"})
+		require.Nil(t, err)
+
+		link, err := cached.FindByID(models.Link{ID: "spring-sale"})
+
+		require.Nil(t, err)
+		assert.Equal(t, "2", link.ID)
+	})
+
+	t.Run("should invalidate old and new keys on update", func(t *testing.T) {
+		_, err := cached.Update(models.Link{ID: "2", Code: "def", Alias: "summer-sale", URL: "https://example.net"})
+		require.Nil(t, err)
+
+		_, err = cached.FindByID(models.Link{ID: "spring-sale"})
+		assert.Equal(t, repository.ErrLinkNotFound, err, "old alias should not be resolved")
+
+		link, err := cached.FindByID(models.Link{ID: "def"})
+		require.Nil(t, err)
+		assert.Equal(t, "https://example.net", link.URL)
+	})
+
+	t.Run("should invalidate keys on delete", func(t *testing.T) {
+		require.Nil(t, cached.Delete(models.Link{ID: "1"}))
+
+		_, err := cached.FindByID(models.Link{ID: "abc"})
+		assert.Equal(t, repository.ErrLinkNotFound, err)
+	})
+
+	stats := cached.CacheStats()
+	assert.True(t, stats.Hits > 0)
+	assert.True(t, stats.Misses > 0)
+}
+
+func TestCachedLinksPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip tests for cached links repository for unit tests")
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	LinkTestSuite(t, &testutils.Repositories{
+		Links: repository.NewCachedLinkRepository(repository.NewSQLLinkRepository(suite.GetDB())),
+		Users: repository.NewUserRepository(suite.GetDB()),
+	})
+}
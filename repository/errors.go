@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"shortener/models"
+)
+
+// Kind is a class of the domain error. Every kind is mapped to a single HTTP status
+type Kind int
+
+const (
+	// Internal errors are not expected, details should not be sent to the client
+	Internal Kind = iota
+	// NotFound is returned when requested object does not exist or is not visible to the user
+	NotFound
+	// Conflict is returned when object could not be saved because of another object
+	Conflict
+	// Validation is returned when user provided data is invalid
+	Validation
+	// Forbidden is returned when user is not allowed to perform an action
+	Forbidden
+	// Unauthorized is returned when credentials or tokens are invalid
+	Unauthorized
+)
+
+// Error is a domain error. Code is stable and could be used by clients, message is human readable
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Details []models.FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Domain errors which are returned by repositories
+var (
+	ErrLinkNotFound = NewError(NotFound, "link_not_found", "Link is not found")
+	ErrUserNotFound = NewError(NotFound, "user_not_found", "User is not found")
+	ErrAliasTaken   = NewError(Conflict, "alias_taken", "Alias is already taken")
+	ErrLoginTaken   = NewError(Conflict, "login_taken", "Login is already taken")
+	ErrForbidden    = NewError(Forbidden, "forbidden", "Action is not allowed")
+	// ErrInvalidPassword is returned when password confirmation of the sensitive action is wrong
+	ErrInvalidPassword = NewError(Forbidden, "invalid_password", "Password is incorrect")
+	// ErrAccountDisabled is returned when disabled user signs in with the correct password
+	ErrAccountDisabled = NewError(Forbidden, "account_disabled", "Account is disabled")
+	// ErrInsufficientRole is returned when the user does not have the role which is required by the route
+	ErrInsufficientRole = NewError(Forbidden, "insufficient_role", "Action requires another role")
+	// ErrSelfAdministration is returned when administrator tries to disable or demote themselves
+	ErrSelfAdministration = NewError(Forbidden, "self_administration", "Administrators can not disable or demote themselves")
+
+	// ErrLinkQuotaExceeded is returned when user has the maximum count of links of the plan
+	ErrLinkQuotaExceeded = NewError(Forbidden, "link_quota_exceeded", "Links quota of the plan is exceeded")
+	// ErrDailyLinkQuotaExceeded is returned when user has created the maximum count of links today
+	ErrDailyLinkQuotaExceeded = NewError(Forbidden, "daily_link_quota_exceeded", "Daily links quota is exceeded, please try again later")
+
+	ErrAPIKeyNotFound      = NewError(NotFound, "api_key_not_found", "API key is not found")
+	ErrInvalidAPIKey       = NewError(Unauthorized, "invalid_api_key", "API key is invalid, revoked or expired")
+	ErrInvalidRefreshToken = NewError(Unauthorized, "invalid_refresh_token", "Refresh token is invalid or expired")
+	ErrInvalidResetToken   = NewError(Validation, "invalid_reset_token", "Reset token is invalid, used or expired")
+	ErrRefreshTokenReused  = NewError(Unauthorized, "refresh_token_reused", "Refresh token is already used, all tokens of the session are revoked")
+
+	// ErrWorkspaceNotFound is returned for unknown workspaces and for workspaces where user is not a member
+	ErrWorkspaceNotFound      = NewError(NotFound, "workspace_not_found", "Workspace is not found")
+	ErrMemberNotFound         = NewError(NotFound, "member_not_found", "Member of the workspace is not found")
+	ErrInvitationNotFound     = NewError(NotFound, "invitation_not_found", "Invitation is not found or expired")
+	ErrAlreadyMember          = NewError(Conflict, "already_member", "User is already a member of the workspace")
+	ErrLastWorkspaceOwner     = NewError(Conflict, "last_workspace_owner", "Workspace should have at least one owner")
+	ErrLastWorkspaceMember    = NewError(Conflict, "last_workspace_member", "The last member can not leave the workspace, delete the workspace instead")
+	ErrInsufficientMemberRole = NewError(Forbidden, "insufficient_member_role", "Action requires another role in the workspace")
+)
+
+// ValidationCode is a code of all validation errors, fields are described by details
+const ValidationCode = "validation_failed"
+
+// NewError creates domain error
+func NewError(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NewValidationError converts models.FieldError and models.ValidationErrors to the domain error.
+// Other errors (e.g. malformed JSON) are used as a message without details.
+func NewValidationError(err error) *Error {
+	validationErr := NewError(Validation, ValidationCode, err.Error())
+
+	switch e := err.(type) {
+	case models.FieldError:
+		validationErr.Details = []models.FieldError{e}
+	case models.ValidationErrors:
+		validationErr.Details = e
+	}
+
+	return validationErr
+}
+
+// KindOf returns kind of the domain error. Internal is returned for other errors
+func KindOf(err error) Kind {
+	if e, ok := err.(*Error); ok {
+		return e.Kind
+	}
+
+	return Internal
+}
+
+// notFoundAs replaces sql.ErrNoRows with the domain error
+func notFoundAs(err error, notFound *Error) error {
+	if err == sql.ErrNoRows {
+		return notFound
+	}
+
+	return err
+}
@@ -3,30 +3,173 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"regexp"
+	"shortener/configuration"
 	"shortener/models"
 	"shortener/models/options"
+	"shortener/shortcode"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 // LinksRepositoryInterface interface
 type LinksRepositoryInterface interface {
 	CountByUser(models.User) (int64, error)
 	CountByUserWithContext(context.Context, models.User) (int64, error)
+	ConsumeClick(models.Link) (bool, error)
+	ConsumeClickWithContext(context.Context, models.Link) (bool, error)
 	Create(models.Link) (*models.Link, error)
 	CreateWithContext(context.Context, models.Link) (*models.Link, error)
 	Delete(models.Link) error
 	DeleteWithContext(context.Context, models.Link) error
 	FindAllByUser(models.User, options.Options) ([]*models.Link, error)
 	FindAllByUserWithContext(context.Context, models.User, options.Options) ([]*models.Link, error)
+	FindAllByWorkspace(models.Workspace, options.Options) ([]*models.Link, error)
+	FindAllByWorkspaceWithContext(context.Context, models.Workspace, options.Options) ([]*models.Link, error)
 	FindByID(models.Link) (*models.Link, error)
 	FindByIDWithContext(context.Context, models.Link) (*models.Link, error)
+	Update(models.Link) (*models.Link, error)
+	UpdateWithContext(context.Context, models.Link) (*models.Link, error)
 }
 
 // LinkRepository type represents to work with usages
-type LinkRepository BaseRepository
+type LinkRepository struct {
+	BaseRepository
+	codes      shortcode.Generator
+	codesErr   error
+	maxRetries int
+}
+
+const uniqueViolation = "23505"
+const linksCodeConstraint = "links_code"
+const linksAliasConstraint = "links_alias"
+
+// linkColumns are selected for every link, "l" is an alias of links table
+const linkColumns = `
+	l.id, coalesce(l.code, ''), coalesce(l.alias, ''), l.url, l.user_id, l.created,
+	l.expires_at, coalesce(l.max_clicks, 0), l.clicks, coalesce(l.password, ''),
+	coalesce(l.workspace_id::text, '')
+	`
 
-// NewSQLLinkRepository creates LinkRepository repository
+// linkKeyColumns are returned by the statements which change links in bulk, see scanLinkKeys
+const linkKeyColumns = "id, coalesce(code, ''), coalesce(alias, '')"
+
+var uuidPattern = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+
+// NewSQLLinkRepository creates LinkRepository repository with code generator from the application configuration.
+// Invalid configuration of the generator is returned by the link creation, it is checked at startup as well.
 func NewSQLLinkRepository(db *sql.DB) LinksRepositoryInterface {
-	return &LinkRepository{db: db}
+	codes, err := shortcode.NewGeneratorFromConfiguration()
+
+	return newSQLLinkRepository(db, codes, err)
+}
+
+// NewSQLLinkRepositoryWithGenerator creates LinkRepository repository which uses specific code generator
+func NewSQLLinkRepositoryWithGenerator(db *sql.DB, codes shortcode.Generator) LinksRepositoryInterface {
+	return newSQLLinkRepository(db, codes, nil)
+}
+
+func newSQLLinkRepository(db *sql.DB, codes shortcode.Generator, codesErr error) *LinkRepository {
+	return &LinkRepository{
+		BaseRepository: BaseRepository{db},
+		codes:          codes,
+		codesErr:       codesErr,
+		maxRetries:     configuration.GetConfiguration().CodeMaxRetries,
+	}
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanLink reads columns listed in linkColumns and additional columns into the link
+func scanLink(row scanner, link *models.Link, additional ...interface{}) error {
+	var expiresAt pq.NullTime
+
+	dest := []interface{}{
+		&link.ID,
+		&link.Code,
+		&link.Alias,
+		&link.URL,
+		&link.UserID,
+		&link.Created,
+		&expiresAt,
+		&link.MaxClicks,
+		&link.Clicks,
+		&link.Password,
+		&link.WorkspaceID,
+	}
+
+	if err := row.Scan(append(dest, additional...)...); err != nil {
+		return err
+	}
+
+	link.ExpiresAt = nil
+
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+
+	link.Protected = link.Password != ""
+	link.UpdateStatus(time.Now())
+
+	return nil
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	pqErr, ok := err.(*pq.Error)
+
+	return ok && pqErr.Code == uniqueViolation && pqErr.Constraint == constraint
+}
+
+// isPathTaken checks whether path is used by any link except the given one either as code or as alias
+func (repository *LinkRepository) isPathTaken(ctx context.Context, path string, link models.Link) (bool, error) {
+	var taken bool
+	statement := `
+		select exists(
+			select 1 from links
+			where (code = $1 or alias = $1)
+			and ($2::uuid is null or id <> $2::uuid)
+		)
+		`
+	id := sql.NullString{String: link.ID, Valid: link.ID != ""}
+	err := repository.db.QueryRowContext(ctx, statement, path, id).Scan(&taken)
+
+	return taken, err
+}
+
+func toNullableColumns(link models.Link) (sql.NullString, pq.NullTime, sql.NullInt64, sql.NullString) {
+	alias := sql.NullString{String: link.Alias, Valid: link.Alias != ""}
+	password := sql.NullString{String: link.Password, Valid: link.Password != ""}
+	maxClicks := sql.NullInt64{Int64: link.MaxClicks, Valid: link.MaxClicks > 0}
+	expiresAt := pq.NullTime{Valid: link.ExpiresAt != nil}
+
+	if expiresAt.Valid {
+		expiresAt.Time = *link.ExpiresAt
+	}
+
+	return alias, expiresAt, maxClicks, password
+}
+
+func (repository *LinkRepository) generateCode(ctx context.Context) (string, error) {
+	var sequence int64
+
+	if repository.codesErr != nil {
+		return "", repository.codesErr
+	}
+
+	if repository.codes.Sequential() {
+		statement := "select nextval('links_code_seq')"
+		err := repository.db.QueryRowContext(ctx, statement).Scan(&sequence)
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return repository.codes.Generate(sequence)
 }
 
 // CountByUser return total count of user's links
@@ -37,10 +180,10 @@ func (repository *LinkRepository) CountByUser(user models.User) (int64, error) {
 	return repository.CountByUserWithContext(ctx, user)
 }
 
-// CountByUserWithContext return total count of user's links
+// CountByUserWithContext return total count of user's personal links, links of the workspaces are not counted
 func (repository *LinkRepository) CountByUserWithContext(ctx context.Context, user models.User) (int64, error) {
 	var count int64
-	statement := "select count(*) from links where user_id = $1"
+	statement := "select count(*) from links where user_id = $1 and workspace_id is null"
 	err := repository.db.QueryRowContext(ctx, statement, user.ID).Scan(&count)
 
 	if err != nil {
@@ -50,6 +193,40 @@ func (repository *LinkRepository) CountByUserWithContext(ctx context.Context, us
 	return count, nil
 }
 
+// ConsumeClick registers click for the link with limited clicks count
+func (repository *LinkRepository) ConsumeClick(link models.Link) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.ConsumeClickWithContext(ctx, link)
+}
+
+// ConsumeClickWithContext registers click for the link with limited clicks count.
+// It returns false when the link is expired. Counter is changed by a single statement,
+// so concurrent clicks could not exceed the limit.
+func (repository *LinkRepository) ConsumeClickWithContext(ctx context.Context, link models.Link) (bool, error) {
+	statement := `
+		update links
+		set clicks = clicks + 1
+		where id = $1
+		and (max_clicks is null or clicks < max_clicks)
+		and (expires_at is null or expires_at > now())
+		`
+	result, err := repository.db.ExecContext(ctx, statement, link.ID)
+
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
 // Create saves user's link to the database
 func (repository *LinkRepository) Create(link models.Link) (*models.Link, error) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -58,16 +235,89 @@ func (repository *LinkRepository) Create(link models.Link) (*models.Link, error)
 	return repository.CreateWithContext(ctx, link)
 }
 
-// CreateWithContext saves user's link to the database
+// CreateWithContext saves user's link (or link of the workspace) to the database. Password of the link should be already hashed.
+// Short code is generated for the link,
+// generation is retried when the code is already taken by another code or alias.
+// ErrAliasTaken is returned when alias of the link is not available.
+// Links quota of the user is checked in the same transaction, ErrLinkQuotaExceeded or ErrDailyLinkQuotaExceeded is returned.
 func (repository *LinkRepository) CreateWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
-	statement := "insert into links (url, user_id) values($1, $2) returning id, created"
-	err := repository.db.QueryRowContext(ctx, statement, link.URL, link.UserID).Scan(&link.ID, &link.Created)
+	statement := `
+		insert into links (url, user_id, code, alias, expires_at, max_clicks, password, workspace_id)
+		select $1::text, $2::uuid, $3::varchar, $4::varchar, $5::timestamptz, $6::integer, $7::varchar, $8::uuid
+		where not exists (select 1 from links where alias = $3)
+		returning id, created
+		`
+	alias, expiresAt, maxClicks, password := toNullableColumns(link)
+	workspaceID := sql.NullString{String: link.WorkspaceID, Valid: link.WorkspaceID != ""}
+
+	if alias.Valid {
+		taken, err := repository.isPathTaken(ctx, link.Alias, models.Link{})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if taken {
+			return nil, ErrAliasTaken
+		}
+	}
+
+	tx, err := repository.db.BeginTx(ctx, nil)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &link, nil
+	defer tx.Rollback()
+
+	if err = lockLinksQuota(ctx, tx, link.UserID); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		code, err := repository.generateCode(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// unique violation aborts the transaction, so the failed insert is rolled back to the savepoint
+		if _, err = tx.ExecContext(ctx, "savepoint link_code"); err != nil {
+			return nil, err
+		}
+
+		err = tx.QueryRowContext(ctx, statement, link.URL, link.UserID, code, alias, expiresAt, maxClicks, password, workspaceID).Scan(&link.ID, &link.Created)
+
+		if isUniqueViolation(err, linksAliasConstraint) {
+			return nil, ErrAliasTaken
+		}
+
+		if err == sql.ErrNoRows || isUniqueViolation(err, linksCodeConstraint) {
+			if _, err = tx.ExecContext(ctx, "rollback to savepoint link_code"); err != nil {
+				return nil, err
+			}
+
+			if attempt < repository.maxRetries {
+				continue
+			}
+
+			return nil, errors.New("Unable to generate unique code for the link")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		link.Code = code
+		link.Protected = password.Valid
+		link.UpdateStatus(time.Now())
+
+		return &link, nil
+	}
 }
 
 func (repository *LinkRepository) Delete(link models.Link) error {
@@ -85,6 +335,32 @@ func (repository *LinkRepository) DeleteWithContext(ctx context.Context, link mo
 	return err
 }
 
+// queryLinks returns links with usages count without private fields
+func (repository *LinkRepository) queryLinks(ctx context.Context, statement string, args ...interface{}) ([]*models.Link, error) {
+	rows, err := repository.db.QueryContext(ctx, statement, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	links := make([]*models.Link, 0)
+
+	for rows.Next() {
+		var link models.Link
+
+		if err = scanLink(rows, &link, &link.UsagesCount); err == nil {
+			link.CleanPrivateFields()
+			links = append(links, &link)
+		} else {
+			return nil, err
+		}
+	}
+
+	return links, nil
+}
+
 // FindAllByUser returns user's links
 func (repository *LinkRepository) FindAllByUser(user models.User, opts options.Options) ([]*models.Link, error) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -93,41 +369,47 @@ func (repository *LinkRepository) FindAllByUser(user models.User, opts options.O
 	return repository.FindAllByUserWithContext(ctx, user, opts)
 }
 
-// FindAllByUserWithContext returns user's links
+// FindAllByUserWithContext returns user's personal links without private fields.
+// Links of the workspaces are listed by FindAllByWorkspaceWithContext.
 func (repository *LinkRepository) FindAllByUserWithContext(ctx context.Context, user models.User, opts options.Options) ([]*models.Link, error) {
 	statement := `
-		select l.id, l.url, l.created, count(u.id) as usagesCount
+		select ` + linkColumns + `, count(u.id) as usagesCount
 		from links l
 		left join usages u
 		on l.id = u.link_id
-		where user_id = $1
+		where l.user_id = $1 and l.workspace_id is null
 		group by l.id
 		order by l.created desc
 		limit $2
 		offset $3
 		`
 
-	rows, err := repository.db.QueryContext(ctx, statement, user.ID, opts.Limit, opts.Offset)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
+	return repository.queryLinks(ctx, statement, user.ID, opts.Limit, opts.Offset)
+}
 
-	links := make([]*models.Link, 0)
+// FindAllByWorkspace returns links of the workspace
+func (repository *LinkRepository) FindAllByWorkspace(workspace models.Workspace, opts options.Options) ([]*models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	for rows.Next() {
-		var link models.Link
+	return repository.FindAllByWorkspaceWithContext(ctx, workspace, opts)
+}
 
-		if err = rows.Scan(&link.ID, &link.URL, &link.Created, &link.UsagesCount); err == nil {
-			links = append(links, &link)
-		} else {
-			return nil, err
-		}
-	}
+// FindAllByWorkspaceWithContext returns links of the workspace without private fields
+func (repository *LinkRepository) FindAllByWorkspaceWithContext(ctx context.Context, workspace models.Workspace, opts options.Options) ([]*models.Link, error) {
+	statement := `
+		select ` + linkColumns + `, count(u.id) as usagesCount
+		from links l
+		left join usages u
+		on l.id = u.link_id
+		where l.workspace_id = $1
+		group by l.id
+		order by l.created desc
+		limit $2
+		offset $3
+		`
 
-	return links, nil
+	return repository.queryLinks(ctx, statement, workspace.ID, opts.Limit, opts.Offset)
 }
 
 // FindByID returns link by link id
@@ -138,14 +420,85 @@ func (repository *LinkRepository) FindByID(link models.Link) (*models.Link, erro
 	return repository.FindByIDWithContext(ctx, link)
 }
 
-// FindByIDWithContext returns link by link id
+// FindByIDWithContext returns link by link id. Short codes, aliases and UUIDs are accepted as id,
+// UUIDs are supported for the links which were created before short codes. ErrLinkNotFound is returned for unknown id.
 func (repository *LinkRepository) FindByIDWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
-	statement := "select id, url, created from links where id = $1"
-	err := repository.db.QueryRowContext(ctx, statement, link.ID).Scan(
-		&link.ID,
-		&link.URL,
-		&link.Created,
-	)
+	// alias wins when generated code is equal to it
+	statement := `
+		select ` + linkColumns + `
+		from links l
+		where l.code = $1 or l.alias = $1
+		order by l.alias = $1 desc nulls last
+		limit 1
+		`
+
+	if uuidPattern.MatchString(link.ID) {
+		statement = "select " + linkColumns + " from links l where l.id = $1"
+	}
+
+	err := scanLink(repository.db.QueryRowContext(ctx, statement, link.ID), &link)
+
+	return &link, notFoundAs(err, ErrLinkNotFound)
+}
+
+// Update saves mutable fields of the link
+func (repository *LinkRepository) Update(link models.Link) (*models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.UpdateWithContext(ctx, link)
+}
+
+// UpdateWithContext saves mutable fields of the link (url, alias, expiration date, max clicks and password hash).
+// ErrAliasTaken is returned when alias of the link is not available.
+func (repository *LinkRepository) UpdateWithContext(ctx context.Context, link models.Link) (*models.Link, error) {
+	statement := `
+		update links l
+		set url = $2, alias = $3, expires_at = $4, max_clicks = $5, password = $6
+		where l.id = $1
+		returning ` + linkColumns
+	alias, expiresAt, maxClicks, password := toNullableColumns(link)
+
+	if alias.Valid {
+		taken, err := repository.isPathTaken(ctx, link.Alias, link)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if taken {
+			return nil, ErrAliasTaken
+		}
+	}
+
+	err := scanLink(repository.db.QueryRowContext(ctx, statement, link.ID, link.URL, alias, expiresAt, maxClicks, password), &link)
+
+	if isUniqueViolation(err, linksAliasConstraint) {
+		return nil, ErrAliasTaken
+	}
+
+	if err != nil {
+		return nil, notFoundAs(err, ErrLinkNotFound)
+	}
+
+	return &link, nil
+}
+
+// scanLinkKeys reads links with linkKeyColumns only, so caches could be invalidated by their keys
+func scanLinkKeys(rows *sql.Rows) ([]models.Link, error) {
+	defer rows.Close()
+
+	links := make([]models.Link, 0)
+
+	for rows.Next() {
+		var link models.Link
+
+		if err := rows.Scan(&link.ID, &link.Code, &link.Alias); err != nil {
+			return nil, err
+		}
+
+		links = append(links, link)
+	}
 
-	return &link, err
+	return links, rows.Err()
 }
@@ -1,7 +1,6 @@
 package repository_test
 
 import (
-	"database/sql"
 	"shortener/models"
 	"shortener/models/options"
 	"shortener/repository"
@@ -35,6 +34,18 @@ func LinkTestSuite(t *testing.T, r *testutils.Repositories) {
 
 		assert.Equal(t, "example.com", link1.URL, "url should be saved correctly in the links table")
 		assert.NotEmpty(t, "example.com", link1.ID, "id should be populated")
+		assert.NotEmpty(t, link1.Code, "code should be populated")
+	})
+
+	t.Run("should fetch link by the short code", func(t *testing.T) {
+		link, err := r.Links.FindByID(models.Link{
+			ID: link1.Code,
+		})
+
+		require.Nil(t, err, "link should be fetched by code")
+
+		assert.Equal(t, link1.ID, link.ID, "ID values should be the same")
+		assert.Equal(t, link1.Code, link.Code, "Code values should be the same")
 	})
 
 	t.Run("should fetch link for the anon user", func(t *testing.T) {
@@ -49,6 +60,48 @@ func LinkTestSuite(t *testing.T, r *testutils.Repositories) {
 		assert.Equal(t, link1.Created, link2.Created, "Created dates should be the same")
 	})
 
+	t.Run("should create link with alias", func(t *testing.T) {
+		alias := "alias-" + link1.Code
+		link, err := r.Links.Create(models.Link{URL: "example.com/alias", UserID: user.ID, Alias: alias})
+
+		require.Nil(t, err, "link with alias should be created")
+
+		found, err := r.Links.FindByID(models.Link{ID: alias})
+
+		require.Nil(t, err, "link should be fetched by alias")
+		assert.Equal(t, link.ID, found.ID, "ID values should be the same")
+
+		_, err = r.Links.Create(models.Link{URL: "example.com/duplicate", UserID: user.ID, Alias: alias})
+
+		assert.Equal(t, repository.ErrAliasTaken, err, "alias should be unique")
+
+		require.Nil(t, r.Links.Delete(*link))
+	})
+
+	t.Run("should consume clicks of the limited link", func(t *testing.T) {
+		link, err := r.Links.Create(models.Link{URL: "example.com/limited", UserID: user.ID, MaxClicks: 1})
+
+		require.Nil(t, err, "limited link should be created")
+		assert.Equal(t, models.LinkActive, link.Status)
+
+		ok, err := r.Links.ConsumeClick(*link)
+
+		require.Nil(t, err)
+		assert.True(t, ok, "first click should be consumed")
+
+		ok, err = r.Links.ConsumeClick(*link)
+
+		require.Nil(t, err)
+		assert.False(t, ok, "second click should be rejected")
+
+		found, err := r.Links.FindByID(models.Link{ID: link.Code})
+
+		require.Nil(t, err)
+		assert.Equal(t, models.LinkExpired, found.Status)
+
+		require.Nil(t, r.Links.Delete(*link))
+	})
+
 	t.Run("should increment links count", func(t *testing.T) {
 		count, err := r.Links.CountByUser(*user)
 
@@ -75,7 +128,7 @@ func LinkTestSuite(t *testing.T, r *testutils.Repositories) {
 
 		_, err = r.Links.FindByID(*link1)
 
-		assert.Equal(t, sql.ErrNoRows, err, "should not find link by ID")
+		assert.Equal(t, repository.ErrLinkNotFound, err, "should not find link by ID")
 	})
 }
 
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"shortener/models"
+)
+
+// PasswordResetRepository type represents repository to work with password reset tokens
+type PasswordResetRepository BaseRepository
+
+// PasswordResetRepositoryInterface interface
+type PasswordResetRepositoryInterface interface {
+	Create(models.PasswordResetToken) (*models.PasswordResetToken, error)
+	CreateWithContext(context.Context, models.PasswordResetToken) (*models.PasswordResetToken, error)
+	Consume(string) (*models.PasswordResetToken, error)
+	ConsumeWithContext(context.Context, string) (*models.PasswordResetToken, error)
+}
+
+// NewPasswordResetRepository creates password reset tokens repository
+func NewPasswordResetRepository(db *sql.DB) PasswordResetRepositoryInterface {
+	return &PasswordResetRepository{
+		db: db,
+	}
+}
+
+// Create saves reset token
+func (repository *PasswordResetRepository) Create(token models.PasswordResetToken) (*models.PasswordResetToken, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateWithContext(ctx, token)
+}
+
+// CreateWithContext saves reset token. Only hash of the token is stored
+func (repository *PasswordResetRepository) CreateWithContext(ctx context.Context, token models.PasswordResetToken) (*models.PasswordResetToken, error) {
+	statement := "insert into password_reset_tokens (user_id, token_hash, expires_at) values ($1, $2, $3) returning id"
+
+	err := repository.db.QueryRowContext(ctx, statement, token.UserID, token.Hash, token.ExpiresAt).Scan(&token.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Consume marks reset token with the given hash as used
+func (repository *PasswordResetRepository) Consume(hash string) (*models.PasswordResetToken, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.ConsumeWithContext(ctx, hash)
+}
+
+// ConsumeWithContext marks reset token with the given hash as used and returns it. Token is changed
+// by a single statement, so it could not be used twice. ErrInvalidResetToken is returned
+// for unknown, used and expired tokens.
+func (repository *PasswordResetRepository) ConsumeWithContext(ctx context.Context, hash string) (*models.PasswordResetToken, error) {
+	token := models.PasswordResetToken{Hash: hash}
+	statement := `
+		update password_reset_tokens
+		set used_at = now()
+		where token_hash = $1 and used_at is null and expires_at > now()
+		returning id, user_id, expires_at
+		`
+
+	err := repository.db.QueryRowContext(ctx, statement, hash).Scan(&token.ID, &token.UserID, &token.ExpiresAt)
+
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidResetToken)
+	}
+
+	return &token, nil
+}
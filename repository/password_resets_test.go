@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"shortener/models"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teris-io/shortid"
+)
+
+func TestPasswordResetsPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip tests for password resets repository for unit tests")
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	users := repository.NewUserRepository(suite.GetDB())
+	tokens := repository.NewTokenRepository(suite.GetDB())
+	resets := repository.NewPasswordResetRepository(suite.GetDB())
+
+	login, _ := shortid.Generate()
+	user, err := users.Create(models.User{Login: login, Password: "password"})
+	require.Nil(t, err)
+	defer users.Delete(*user)
+
+	t.Run("should consume reset token once", func(t *testing.T) {
+		plain, token, err := models.NewPasswordResetToken(user.ID)
+		require.Nil(t, err)
+		_, err = resets.Create(token)
+		require.Nil(t, err)
+
+		consumed, err := resets.Consume(models.HashPasswordResetToken(plain))
+		require.Nil(t, err)
+		assert.Equal(t, user.ID, consumed.UserID)
+
+		_, err = resets.Consume(models.HashPasswordResetToken(plain))
+		assert.Equal(t, repository.ErrInvalidResetToken, err, "token should not be used twice")
+	})
+
+	t.Run("should reject expired reset token", func(t *testing.T) {
+		plain, token, _ := models.NewPasswordResetToken(user.ID)
+		token.ExpiresAt = time.Now().Add(-time.Minute)
+		_, err := resets.Create(token)
+		require.Nil(t, err)
+
+		_, err = resets.Consume(models.HashPasswordResetToken(plain))
+		assert.Equal(t, repository.ErrInvalidResetToken, err)
+	})
+
+	t.Run("should invalidate sessions when password is changed", func(t *testing.T) {
+		plainRefresh, refresh, _ := models.NewRefreshToken(user.ID)
+		_, err := tokens.Create(refresh)
+		require.Nil(t, err)
+		plainReset, reset, _ := models.NewPasswordResetToken(user.ID)
+		_, err = resets.Create(reset)
+		require.Nil(t, err)
+		claims := models.Claims{User: *user, TokenID: "before-change", IssuedAt: time.Now().Add(-time.Minute)}
+
+		require.Nil(t, users.UpdatePassword(models.User{ID: user.ID, Password: "new password hash"}))
+
+		revoked, err := tokens.IsAccessTokenRevoked(claims)
+		require.Nil(t, err)
+		assert.True(t, revoked, "access tokens issued before the change should be rejected")
+
+		claims.IssuedAt = time.Now().Add(time.Second)
+		revoked, err = tokens.IsAccessTokenRevoked(claims)
+		require.Nil(t, err)
+		assert.False(t, revoked, "new access tokens should be accepted")
+
+		_, err = tokens.Rotate(models.HashRefreshToken(plainRefresh), models.RefreshToken{Hash: "next", ExpiresAt: time.Now().Add(time.Hour)})
+		assert.Equal(t, repository.ErrInvalidRefreshToken, err, "refresh tokens should be revoked")
+
+		_, err = resets.Consume(models.HashPasswordResetToken(plainReset))
+		assert.Equal(t, repository.ErrInvalidResetToken, err, "reset tokens should be revoked")
+
+		credentials, err := users.FindCredentials(user.ID)
+		require.Nil(t, err)
+		assert.Equal(t, "new password hash", credentials.Password)
+	})
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"shortener/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// QuotaRepository type represents repository to work with quotas of the users.
+// Links are counted directly, tracked clicks are counted by months in click_counters table.
+type QuotaRepository BaseRepository
+
+// QuotaRepositoryInterface interface
+type QuotaRepositoryInterface interface {
+	SaveUsages([]models.Usage) ([]models.Usage, error)
+	SaveUsagesWithContext(context.Context, []models.Usage) ([]models.Usage, error)
+	Usage(models.User) (*models.QuotaUsage, error)
+	UsageWithContext(context.Context, models.User) (*models.QuotaUsage, error)
+}
+
+// NewQuotaRepository creates quotas repository
+func NewQuotaRepository(db *sql.DB) QuotaRepositoryInterface {
+	return &QuotaRepository{
+		db: db,
+	}
+}
+
+// SaveUsages counts clicks of the usages and saves usages which are allowed to be tracked
+func (repository *QuotaRepository) SaveUsages(usages []models.Usage) ([]models.Usage, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.SaveUsagesWithContext(ctx, usages)
+}
+
+// SaveUsagesWithContext counts clicks of the usages for the owners of the links and saves usages
+// which are allowed to be tracked in the same transaction, so failed inserts do not consume the quota.
+// Clicks over the monthly quota of the owner are not tracked, usages of the deleted links are skipped as well.
+// Saved usages are returned.
+func (repository *QuotaRepository) SaveUsagesWithContext(ctx context.Context, usages []models.Usage) ([]models.Usage, error) {
+	type owner struct {
+		id     string
+		quota  models.Quota
+		usages []models.Usage
+	}
+
+	linkIDs := make([]string, 0, len(usages))
+
+	for _, usage := range usages {
+		linkIDs = append(linkIDs, usage.UrlID)
+	}
+
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	statement := `
+		select l.id, u.id, u.login, u.plan, u.role
+		from links l
+		join users u
+		on u.id = l.user_id
+		where l.id = any($1::uuid[])
+		order by u.id
+		`
+	rows, err := tx.QueryContext(ctx, statement, pq.Array(linkIDs))
+
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make([]*owner, 0)
+	ownersByID := make(map[string]*owner)
+	linkOwners := make(map[string]*owner)
+
+	for rows.Next() {
+		var linkID, userID, login, plan, role string
+
+		if err = rows.Scan(&linkID, &userID, &login, &plan, &role); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if _, ok := ownersByID[userID]; !ok {
+			ownersByID[userID] = &owner{id: userID, quota: models.NewQuota(plan, login, role)}
+			owners = append(owners, ownersByID[userID])
+		}
+
+		linkOwners[linkID] = ownersByID[userID]
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, usage := range usages {
+		if linkOwner, ok := linkOwners[usage.UrlID]; ok {
+			linkOwner.usages = append(linkOwner.usages, usage)
+		}
+	}
+
+	allowed := make([]models.Usage, 0, len(usages))
+	statement = `
+		insert into click_counters (user_id, month, clicks)
+		values ($1, date_trunc('month', now())::date, 0)
+		on conflict (user_id, month) do update set clicks = click_counters.clicks
+		returning clicks
+		`
+
+	for _, linkOwner := range owners {
+		var clicks int64
+
+		// the row is locked by the upsert, so concurrent writers do not exceed the quota.
+		// Owners are sorted, so concurrent transactions lock rows in the same order.
+		if err = tx.QueryRowContext(ctx, statement, linkOwner.id).Scan(&clicks); err != nil {
+			return nil, err
+		}
+
+		count := int64(len(linkOwner.usages))
+
+		if limit := linkOwner.quota.MaxClicksPerMonth; limit > 0 && clicks+count > limit {
+			count = limit - clicks
+
+			if count < 0 {
+				count = 0
+			}
+		}
+
+		update := "update click_counters set clicks = clicks + $2 where user_id = $1 and month = date_trunc('month', now())::date"
+
+		if _, err = tx.ExecContext(ctx, update, linkOwner.id, count); err != nil {
+			return nil, err
+		}
+
+		allowed = append(allowed, linkOwner.usages[:count]...)
+	}
+
+	if err = insertUsages(ctx, tx, allowed); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return allowed, nil
+}
+
+// lockLinksQuota locks the user until the end of the transaction and checks links quota of the user,
+// so concurrent transactions could not exceed it. ErrLinkQuotaExceeded or ErrDailyLinkQuotaExceeded is returned.
+// Only personal links are counted, links of the workspaces (including ones transferred to the owner) are not.
+func lockLinksQuota(ctx context.Context, tx *sql.Tx, userID string) error {
+	var plan, login, role string
+	var links, linksToday int64
+
+	statement := "select plan, login, role from users where id = $1 for no key update"
+
+	if err := tx.QueryRowContext(ctx, statement, userID).Scan(&plan, &login, &role); err != nil {
+		return notFoundAs(err, ErrUserNotFound)
+	}
+
+	quota := models.NewQuota(plan, login, role)
+
+	if quota.MaxLinks == 0 && quota.MaxLinksPerDay == 0 {
+		return nil
+	}
+
+	statement = `
+		select count(*), count(*) filter (where created >= date_trunc('day', now()))
+		from links
+		where user_id = $1 and workspace_id is null
+		`
+
+	if err := tx.QueryRowContext(ctx, statement, userID).Scan(&links, &linksToday); err != nil {
+		return err
+	}
+
+	if quota.MaxLinks > 0 && links >= quota.MaxLinks {
+		return ErrLinkQuotaExceeded
+	}
+
+	if quota.MaxLinksPerDay > 0 && linksToday >= quota.MaxLinksPerDay {
+		return ErrDailyLinkQuotaExceeded
+	}
+
+	return nil
+}
+
+// Usage returns current consumption of the quota by the user
+func (repository *QuotaRepository) Usage(user models.User) (*models.QuotaUsage, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.UsageWithContext(ctx, user)
+}
+
+// UsageWithContext returns current consumption of the quota by the user together with limits of the user plan.
+// Links of the workspaces are not counted, see lockLinksQuota.
+// Daily links quota is reset at the start of the day, clicks quota is reset at the start of the month (database time).
+func (repository *QuotaRepository) UsageWithContext(ctx context.Context, user models.User) (*models.QuotaUsage, error) {
+	var plan, login, role string
+	var links, linksToday, clicks int64
+	var dayEnd, monthEnd time.Time
+
+	statement := `
+		select
+			u.plan,
+			u.login,
+			u.role,
+			(select count(*) from links l where l.user_id = u.id and l.workspace_id is null),
+			(select count(*) from links l where l.user_id = u.id and l.workspace_id is null and l.created >= date_trunc('day', now())),
+			coalesce((select c.clicks from click_counters c where c.user_id = u.id and c.month = date_trunc('month', now())::date), 0),
+			date_trunc('day', now()) + interval '1 day',
+			date_trunc('month', now()) + interval '1 month'
+		from users u
+		where u.id = $1
+		`
+	err := repository.db.QueryRowContext(ctx, statement, user.ID).Scan(&plan, &login, &role, &links, &linksToday, &clicks, &dayEnd, &monthEnd)
+
+	if err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+
+	quota := models.NewQuota(plan, login, role)
+
+	return &models.QuotaUsage{
+		Plan:            plan,
+		Links:           models.NewQuotaCounter(links, quota.MaxLinks, nil),
+		LinksToday:      models.NewQuotaCounter(linksToday, quota.MaxLinksPerDay, &dayEnd),
+		ClicksThisMonth: models.NewQuotaCounter(clicks, quota.MaxClicksPerMonth, &monthEnd),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package repository_test
+
+import (
+	"shortener/models"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teris-io/shortid"
+)
+
+func TestQuotasPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip tests for quotas repository for unit tests")
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	users := repository.NewUserRepository(suite.GetDB())
+	links := repository.NewSQLLinkRepository(suite.GetDB())
+	quotas := repository.NewQuotaRepository(suite.GetDB())
+
+	login, _ := shortid.Generate()
+	user, err := users.Create(models.User{Login: login, Password: "password"})
+	require.Nil(t, err)
+	defer users.Delete(*user)
+
+	link, err := links.Create(models.Link{URL: "https://example.com", UserID: user.ID})
+	require.Nil(t, err)
+
+	t.Run("should report links and clicks of the user", func(t *testing.T) {
+		usage, err := quotas.Usage(*user)
+
+		require.Nil(t, err)
+		assert.Equal(t, models.PlanFree, usage.Plan)
+		assert.Equal(t, int64(1), usage.Links.Used)
+		assert.Equal(t, int64(1), usage.LinksToday.Used)
+		assert.Equal(t, int64(0), usage.ClicksThisMonth.Used)
+		assert.NotNil(t, usage.LinksToday.ResetsAt)
+	})
+
+	t.Run("should save usages and skip usages of the unknown links", func(t *testing.T) {
+		saved, err := quotas.SaveUsages([]models.Usage{
+			{UrlID: link.ID},
+			{UrlID: link.ID},
+			{UrlID: "00000000-0000-0000-0000-000000000000"},
+		})
+
+		require.Nil(t, err)
+		assert.Len(t, saved, 2)
+
+		usage, err := quotas.Usage(*user)
+
+		require.Nil(t, err)
+		assert.Equal(t, int64(2), usage.ClicksThisMonth.Used)
+	})
+
+	t.Run("should not limit users with unlimited plan", func(t *testing.T) {
+		_, err := users.SetPlan(user.ID, models.PlanUnlimited)
+		require.Nil(t, err)
+
+		usage, err := quotas.Usage(*user)
+
+		require.Nil(t, err)
+		assert.Equal(t, models.PlanUnlimited, usage.Plan)
+		assert.Nil(t, usage.Links.Remaining)
+		assert.False(t, usage.ClicksThisMonth.IsExceeded())
+	})
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"shortener/models"
+	"time"
+)
+
+// TokenRepository type represents repository to work with refresh tokens and revoked access tokens
+type TokenRepository BaseRepository
+
+// TokenRepositoryInterface interface
+type TokenRepositoryInterface interface {
+	Create(models.RefreshToken) (*models.RefreshToken, error)
+	CreateWithContext(context.Context, models.RefreshToken) (*models.RefreshToken, error)
+	Rotate(string, models.RefreshToken) (*models.RefreshToken, error)
+	RotateWithContext(context.Context, string, models.RefreshToken) (*models.RefreshToken, error)
+	RevokeFamily(string, string) error
+	RevokeFamilyWithContext(context.Context, string, string) error
+	RevokeAccessToken(string, time.Time) error
+	RevokeAccessTokenWithContext(context.Context, string, time.Time) error
+	IsAccessTokenRevoked(models.Claims) (bool, error)
+	IsAccessTokenRevokedWithContext(context.Context, models.Claims) (bool, error)
+}
+
+// NewTokenRepository creates tokens repository
+func NewTokenRepository(db *sql.DB) TokenRepositoryInterface {
+	return &TokenRepository{
+		db: db,
+	}
+}
+
+// Create saves refresh token
+func (repository *TokenRepository) Create(token models.RefreshToken) (*models.RefreshToken, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateWithContext(ctx, token)
+}
+
+// CreateWithContext saves refresh token. New family is started when family of the token is empty
+func (repository *TokenRepository) CreateWithContext(ctx context.Context, token models.RefreshToken) (*models.RefreshToken, error) {
+	statement := `
+		insert into refresh_tokens (user_id, family_id, token_hash, expires_at)
+		values ($1, coalesce($2::uuid, uuid_generate_v4()), $3, $4)
+		returning id, family_id
+		`
+	familyID := sql.NullString{String: token.FamilyID, Valid: token.FamilyID != ""}
+
+	err := repository.db.QueryRowContext(ctx, statement, token.UserID, familyID, token.Hash, token.ExpiresAt).Scan(&token.ID, &token.FamilyID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Rotate exchanges refresh token with the given hash for the next one
+func (repository *TokenRepository) Rotate(hash string, next models.RefreshToken) (*models.RefreshToken, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.RotateWithContext(ctx, hash, next)
+}
+
+// RotateWithContext marks refresh token with the given hash as used and saves the next token
+// of the same user and family. When used token is presented again the whole family is revoked
+// and ErrRefreshTokenReused is returned, because the token is likely stolen.
+func (repository *TokenRepository) RotateWithContext(ctx context.Context, hash string, next models.RefreshToken) (*models.RefreshToken, error) {
+	var used, revoked bool
+	var expiresAt time.Time
+	var current models.RefreshToken
+
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	statement := `
+		select id, user_id, family_id, expires_at, used_at is not null, revoked_at is not null
+		from refresh_tokens
+		where token_hash = $1
+		for update
+		`
+	err = tx.QueryRowContext(ctx, statement, hash).Scan(&current.ID, &current.UserID, &current.FamilyID, &expiresAt, &used, &revoked)
+
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidRefreshToken)
+	}
+
+	if used {
+		statement = "update refresh_tokens set revoked_at = now() where family_id = $1 and revoked_at is null"
+
+		if _, err = tx.ExecContext(ctx, statement, current.FamilyID); err != nil {
+			return nil, err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrRefreshTokenReused
+	}
+
+	if revoked || !expiresAt.After(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if _, err = tx.ExecContext(ctx, "update refresh_tokens set used_at = now() where id = $1", current.ID); err != nil {
+		return nil, err
+	}
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	statement = `
+		insert into refresh_tokens (user_id, family_id, token_hash, expires_at)
+		values ($1, $2, $3, $4)
+		returning id
+		`
+
+	if err = tx.QueryRowContext(ctx, statement, next.UserID, next.FamilyID, next.Hash, next.ExpiresAt).Scan(&next.ID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// RevokeFamily revokes refresh token with the given hash and all tokens of its family
+func (repository *TokenRepository) RevokeFamily(hash string, userID string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.RevokeFamilyWithContext(ctx, hash, userID)
+}
+
+// RevokeFamilyWithContext revokes refresh token with the given hash and all tokens of its family.
+// Only tokens of the user are revoked, ErrInvalidRefreshToken is returned for unknown tokens.
+func (repository *TokenRepository) RevokeFamilyWithContext(ctx context.Context, hash string, userID string) error {
+	statement := `
+		update refresh_tokens
+		set revoked_at = coalesce(revoked_at, now())
+		where family_id = (select family_id from refresh_tokens where token_hash = $1 and user_id = $2)
+		`
+	result, err := repository.db.ExecContext(ctx, statement, hash, userID)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return ErrInvalidRefreshToken
+	}
+
+	return nil
+}
+
+// RevokeAccessToken adds access token id (jti) to the denylist
+func (repository *TokenRepository) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.RevokeAccessTokenWithContext(ctx, tokenID, expiresAt)
+}
+
+// RevokeAccessTokenWithContext adds access token id (jti) to the denylist until the token is expired.
+// Entries of the expired tokens are removed, they are rejected anyway.
+func (repository *TokenRepository) RevokeAccessTokenWithContext(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if _, err := repository.db.ExecContext(ctx, "delete from revoked_access_tokens where expires_at < now()"); err != nil {
+		return err
+	}
+
+	statement := `
+		insert into revoked_access_tokens (jti, expires_at)
+		values ($1, $2)
+		on conflict (jti) do nothing
+		`
+	_, err := repository.db.ExecContext(ctx, statement, tokenID, expiresAt)
+
+	return err
+}
+
+// IsAccessTokenRevoked checks whether access token is revoked
+func (repository *TokenRepository) IsAccessTokenRevoked(claims models.Claims) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.IsAccessTokenRevokedWithContext(ctx, claims)
+}
+
+// IsAccessTokenRevokedWithContext checks whether access token id (jti) is in the denylist
+// or the token is issued before the password (or role) of the user is changed.
+// Tokens of the disabled and deleted users are revoked as well.
+func (repository *TokenRepository) IsAccessTokenRevokedWithContext(ctx context.Context, claims models.Claims) (bool, error) {
+	var revoked bool
+	statement := `
+		select exists(select 1 from revoked_access_tokens where jti = $1)
+		or exists(select 1 from users where id = $2 and (tokens_valid_after > $3 or disabled_at is not null))
+		or not exists(select 1 from users where id = $2)
+		`
+
+	err := repository.db.QueryRowContext(ctx, statement, claims.TokenID, claims.ID, claims.IssuedAt).Scan(&revoked)
+
+	return revoked, err
+}
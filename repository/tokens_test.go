@@ -0,0 +1,104 @@
+package repository_test
+
+import (
+	"shortener/models"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teris-io/shortid"
+)
+
+func TestTokensPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip tests for tokens repository for unit tests")
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	users := repository.NewUserRepository(suite.GetDB())
+	tokens := repository.NewTokenRepository(suite.GetDB())
+
+	login, _ := shortid.Generate()
+	user, err := users.Create(models.User{Login: login, Password: "password"})
+	require.Nil(t, err)
+	defer users.Delete(*user)
+
+	plain, token, err := models.NewRefreshToken(user.ID)
+	require.Nil(t, err)
+	created, err := tokens.Create(token)
+	require.Nil(t, err)
+	require.NotEmpty(t, created.FamilyID, "new family should be started")
+
+	nextPlain, next, _ := models.NewRefreshToken("")
+
+	t.Run("should rotate refresh token", func(t *testing.T) {
+		rotated, err := tokens.Rotate(models.HashRefreshToken(plain), next)
+
+		require.Nil(t, err)
+		assert.Equal(t, user.ID, rotated.UserID)
+		assert.Equal(t, created.FamilyID, rotated.FamilyID)
+	})
+
+	t.Run("should revoke family when used token is presented again", func(t *testing.T) {
+		_, err := tokens.Rotate(models.HashRefreshToken(plain), models.RefreshToken{Hash: "unused", ExpiresAt: time.Now().Add(time.Hour)})
+		assert.Equal(t, repository.ErrRefreshTokenReused, err)
+
+		_, err = tokens.Rotate(models.HashRefreshToken(nextPlain), models.RefreshToken{Hash: "unused", ExpiresAt: time.Now().Add(time.Hour)})
+		assert.Equal(t, repository.ErrInvalidRefreshToken, err, "tokens of the revoked family should be rejected")
+	})
+
+	t.Run("should reject unknown refresh token", func(t *testing.T) {
+		_, err := tokens.Rotate(models.HashRefreshToken("unknown"), next)
+
+		assert.Equal(t, repository.ErrInvalidRefreshToken, err)
+	})
+
+	t.Run("should revoke family on logout", func(t *testing.T) {
+		plain, token, _ := models.NewRefreshToken(user.ID)
+		_, err := tokens.Create(token)
+		require.Nil(t, err)
+
+		assert.Equal(t, repository.ErrInvalidRefreshToken, tokens.RevokeFamily(models.HashRefreshToken(plain), "00000000-0000-0000-0000-000000000000"), "tokens of other users should not be revoked")
+		require.Nil(t, tokens.RevokeFamily(models.HashRefreshToken(plain), user.ID))
+
+		_, err = tokens.Rotate(models.HashRefreshToken(plain), models.RefreshToken{Hash: "revoked", ExpiresAt: time.Now().Add(time.Hour)})
+		assert.Equal(t, repository.ErrInvalidRefreshToken, err)
+	})
+
+	t.Run("should deny revoked access tokens", func(t *testing.T) {
+		tokenID, _ := shortid.Generate()
+		claims := models.Claims{User: *user, TokenID: tokenID, IssuedAt: time.Now()}
+
+		revoked, err := tokens.IsAccessTokenRevoked(claims)
+		require.Nil(t, err)
+		assert.False(t, revoked)
+
+		require.Nil(t, tokens.RevokeAccessToken(tokenID, time.Now().Add(time.Hour)))
+		require.Nil(t, tokens.RevokeAccessToken(tokenID, time.Now().Add(time.Hour)), "token could be revoked twice")
+
+		revoked, err = tokens.IsAccessTokenRevoked(claims)
+		require.Nil(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("should deny access tokens of the deleted users", func(t *testing.T) {
+		deletedUser, err := users.Create(models.User{Login: login + "-deleted", Password: "password"})
+		require.Nil(t, err)
+
+		tokenID, _ := shortid.Generate()
+		claims := models.Claims{User: *deletedUser, TokenID: tokenID, IssuedAt: time.Now()}
+
+		_, err = users.Delete(*deletedUser)
+		require.Nil(t, err)
+
+		revoked, err := tokens.IsAccessTokenRevoked(claims)
+		require.Nil(t, err)
+		assert.True(t, revoked)
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"shortener/models"
+	"strconv"
+	"strings"
 )
 
 // UsageRepository type represents to work with usages
@@ -11,8 +13,26 @@ type UsageRepository BaseRepository
 
 // UsageRepositoryInterface interface
 type UsageRepositoryInterface interface {
-	Create(string) (*models.Usage, error)
-	CreateWithContext(context.Context, string) (*models.Usage, error)
+	Create(models.Usage) (*models.Usage, error)
+	CreateWithContext(context.Context, models.Usage) (*models.Usage, error)
+	CreateMany([]models.Usage) error
+	CreateManyWithContext(context.Context, []models.Usage) error
+	StatsByLink(string, models.StatsQuery) (*models.LinkStats, error)
+	StatsByLinkWithContext(context.Context, string, models.StatsQuery) (*models.LinkStats, error)
+}
+
+// statsTopLimit is a count of the most popular values returned for each dimension
+const statsTopLimit = 10
+
+// statsDimensions maps stats fields to the keys of the usage meta
+var statsDimensions = []struct {
+	key   string
+	field func(*models.LinkStats) *[]models.StatsValue
+}{
+	{"referrer", func(s *models.LinkStats) *[]models.StatsValue { return &s.Referrers }},
+	{"browser", func(s *models.LinkStats) *[]models.StatsValue { return &s.Browsers }},
+	{"os", func(s *models.LinkStats) *[]models.StatsValue { return &s.OperatingSystems }},
+	{"country", func(s *models.LinkStats) *[]models.StatsValue { return &s.Countries }},
 }
 
 // NewUsageRepository creates users repository
@@ -23,24 +43,181 @@ func NewUsageRepository(db *sql.DB) UsageRepositoryInterface {
 }
 
 // Create saves new usage object to the database
-func (repository *UsageRepository) Create(UrlID string) (*models.Usage, error) {
+func (repository *UsageRepository) Create(usage models.Usage) (*models.Usage, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	return repository.CreateWithContext(ctx, UrlID)
+	return repository.CreateWithContext(ctx, usage)
 }
 
-// CreateWithContext saves new usage object to the database
-func (repository *UsageRepository) CreateWithContext(ctx context.Context, UrlID string) (*models.Usage, error) {
-	usage := models.Usage{
-		UrlID: UrlID,
-	}
-	statement := "insert into usages (link_id) values($1) returning id, created"
+// CreateWithContext saves new usage object with click metadata to the database
+func (repository *UsageRepository) CreateWithContext(ctx context.Context, usage models.Usage) (*models.Usage, error) {
+	statement := "insert into usages (link_id, meta) values($1, $2) returning id, created"
 
-	err := repository.db.QueryRowContext(ctx, statement, UrlID).Scan(
+	err := repository.db.QueryRowContext(ctx, statement, usage.UrlID, usage.Meta).Scan(
 		&usage.ID,
 		&usage.Created,
 	)
 
 	return &usage, err
 }
+
+// CreateMany saves usages to the database using a single statement
+func (repository *UsageRepository) CreateMany(usages []models.Usage) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateManyWithContext(ctx, usages)
+}
+
+// CreateManyWithContext saves usages to the database using a single statement.
+// Created time of the usage is used when it is set, otherwise current time is used.
+func (repository *UsageRepository) CreateManyWithContext(ctx context.Context, usages []models.Usage) error {
+	return insertUsages(ctx, repository.db, usages)
+}
+
+// execer is implemented by both database and transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertUsages saves usages using a single statement, see CreateManyWithContext
+func insertUsages(ctx context.Context, db execer, usages []models.Usage) error {
+	if len(usages) == 0 {
+		return nil
+	}
+
+	var builder strings.Builder
+	args := make([]interface{}, 0, len(usages)*3)
+
+	builder.WriteString("insert into usages (link_id, meta, created) values ")
+
+	for i, usage := range usages {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+
+		n := len(args)
+		builder.WriteString("($" + strconv.Itoa(n+1) + ", $" + strconv.Itoa(n+2) + ", ")
+		builder.WriteString("coalesce($" + strconv.Itoa(n+3) + "::timestamptz::timestamp, now()))")
+
+		var created interface{}
+
+		if !usage.Created.IsZero() {
+			created = usage.Created
+		}
+
+		args = append(args, usage.UrlID, usage.Meta, created)
+	}
+
+	_, err := db.ExecContext(ctx, builder.String(), args...)
+
+	return err
+}
+
+// StatsByLink returns statistics of the link usages
+func (repository *UsageRepository) StatsByLink(linkID string, query models.StatsQuery) (*models.LinkStats, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.StatsByLinkWithContext(ctx, linkID, query)
+}
+
+// StatsByLinkWithContext returns time series and the most popular referrers, browsers, operating systems
+// and countries of the link usages within the range. Every bucket of the range is present in the time series,
+// buckets without usages have zero clicks. Bucket of the query should be validated by the caller.
+func (repository *UsageRepository) StatsByLinkWithContext(ctx context.Context, linkID string, query models.StatsQuery) (*models.LinkStats, error) {
+	stats := models.LinkStats{
+		StatsQuery:       query,
+		Series:           make([]models.StatsPoint, 0),
+		Referrers:        make([]models.StatsValue, 0),
+		Browsers:         make([]models.StatsValue, 0),
+		OperatingSystems: make([]models.StatsValue, 0),
+		Countries:        make([]models.StatsValue, 0),
+	}
+
+	// created is stored in the time zone of the session, so range is converted the same way
+	statement := `
+		select b.bucket::timestamptz, count(u.id)
+		from generate_series(
+			date_trunc($2, $3::timestamptz::timestamp),
+			$4::timestamptz::timestamp - interval '1 microsecond',
+			('1 ' || $2)::interval
+		) as b(bucket)
+		left join usages u
+		on u.link_id = $1
+		and date_trunc($2, u.created) = b.bucket
+		and u.created >= $3::timestamptz::timestamp and u.created < $4::timestamptz::timestamp
+		group by b.bucket
+		order by b.bucket
+		`
+
+	rows, err := repository.db.QueryContext(ctx, statement, linkID, query.Bucket, query.From, query.To)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var point models.StatsPoint
+
+		if err = rows.Scan(&point.Time, &point.Clicks); err != nil {
+			return nil, err
+		}
+
+		stats.Total += point.Clicks
+		stats.Series = append(stats.Series, point)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, dimension := range statsDimensions {
+		values, err := repository.topValues(ctx, linkID, dimension.key, query)
+
+		if err != nil {
+			return nil, err
+		}
+
+		*dimension.field(&stats) = values
+	}
+
+	return &stats, nil
+}
+
+// topValues returns the most popular values of the usage meta key
+func (repository *UsageRepository) topValues(ctx context.Context, linkID string, key string, query models.StatsQuery) ([]models.StatsValue, error) {
+	statement := `
+		select coalesce(nullif(meta->>$2, ''), 'unknown') as value, count(*) as clicks
+		from usages
+		where link_id = $1 and created >= $3::timestamptz::timestamp and created < $4::timestamptz::timestamp
+		group by value
+		order by clicks desc, value
+		limit $5
+		`
+
+	rows, err := repository.db.QueryContext(ctx, statement, linkID, key, query.From, query.To, statsTopLimit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	values := make([]models.StatsValue, 0)
+
+	for rows.Next() {
+		var value models.StatsValue
+
+		if err = rows.Scan(&value.Value, &value.Clicks); err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
@@ -0,0 +1,71 @@
+package repository_test
+
+import (
+	"shortener/models"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teris-io/shortid"
+)
+
+func TestUsageStatsPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip tests for usages repository for unit tests")
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	users := repository.NewUserRepository(suite.GetDB())
+	links := repository.NewSQLLinkRepository(suite.GetDB())
+	usages := repository.NewUsageRepository(suite.GetDB())
+
+	login, _ := shortid.Generate()
+	user, err := users.Create(models.User{Login: login, Password: "password"})
+	require.Nil(t, err)
+	defer users.Delete(*user)
+
+	link, err := links.Create(models.Link{URL: "https://example.com", UserID: user.ID})
+	require.Nil(t, err)
+
+	day := time.Date(2019, 4, 1, 0, 0, 0, 0, time.Local)
+	require.Nil(t, usages.CreateMany([]models.Usage{
+		{UrlID: link.ID, Created: day.Add(10 * time.Minute), Meta: models.UsageMeta{Browser: "Firefox", Referrer: "https://github.com"}},
+		{UrlID: link.ID, Created: day.Add(50 * time.Minute), Meta: models.UsageMeta{Browser: "Firefox"}},
+		{UrlID: link.ID, Created: day.Add(3 * time.Hour), Meta: models.UsageMeta{Browser: "Chrome"}},
+		// outside of the range
+		{UrlID: link.ID, Created: day.Add(-time.Minute)},
+		{UrlID: link.ID, Created: day.Add(4 * time.Hour)},
+	}))
+
+	t.Run("should aggregate usages by buckets and fill empty buckets", func(t *testing.T) {
+		stats, err := usages.StatsByLink(link.ID, models.StatsQuery{From: day, To: day.Add(4 * time.Hour), Bucket: "hour"})
+
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), stats.Total)
+		require.Len(t, stats.Series, 4)
+
+		for i, clicks := range []int64{2, 0, 0, 1} {
+			assert.True(t, day.Add(time.Duration(i)*time.Hour).Equal(stats.Series[i].Time), "bucket %d should start at %s", i, stats.Series[i].Time)
+			assert.Equal(t, clicks, stats.Series[i].Clicks)
+		}
+
+		assert.Equal(t, []models.StatsValue{{Value: "Firefox", Clicks: 2}, {Value: "Chrome", Clicks: 1}}, stats.Browsers)
+		assert.Equal(t, []models.StatsValue{{Value: "unknown", Clicks: 2}, {Value: "https://github.com", Clicks: 1}}, stats.Referrers)
+	})
+
+	t.Run("should start the series at the bucket of the range start", func(t *testing.T) {
+		stats, err := usages.StatsByLink(link.ID, models.StatsQuery{From: day.Add(30 * time.Minute), To: day.Add(2 * time.Hour), Bucket: "hour"})
+
+		require.Nil(t, err)
+		require.Len(t, stats.Series, 2)
+		assert.True(t, day.Equal(stats.Series[0].Time))
+		assert.Equal(t, int64(1), stats.Series[0].Clicks, "usages before the range start should not be counted")
+		assert.Equal(t, int64(0), stats.Series[1].Clicks)
+	})
+}
@@ -4,12 +4,22 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"shortener/models"
 	"shortener/models/options"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// likePattern escapes special characters of the LIKE patterns
+var likePattern = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+const usersLoginConstraint = "users_login_key"
+
+// userColumns are selected for every user
+const userColumns = "id, login, password, role, plan, created, disabled_at"
+
 // UserRepository type represent repository to work with UserRepository
 type UserRepository struct {
 	BaseRepository
@@ -20,12 +30,34 @@ type UserRepository struct {
 type UserRepositoryInterface interface {
 	Create(models.User) (*models.User, error)
 	CreateWithContext(context.Context, models.User) (*models.User, error)
-	Delete(models.User) error
-	DeleteWithContext(context.Context, models.User) error
+	Delete(models.User) ([]models.Link, error)
+	DeleteWithContext(context.Context, models.User) ([]models.Link, error)
 	FindByID(string, options.Options) (*models.User, error)
 	FindByIDWithContext(context.Context, string, options.Options) (*models.User, error)
 	FindByLogin(string) (*models.User, error)
 	FindByLoginWithContext(context.Context, string) (*models.User, error)
+	FindCredentials(string) (*models.User, error)
+	FindCredentialsWithContext(context.Context, string) (*models.User, error)
+	Update(models.User) (*models.User, error)
+	UpdateWithContext(context.Context, models.User) (*models.User, error)
+	UpdatePassword(models.User) error
+	UpdatePasswordWithContext(context.Context, models.User) error
+	Search(string, options.Options) ([]*models.User, error)
+	SearchWithContext(context.Context, string, options.Options) ([]*models.User, error)
+	SetDisabled(string, bool) (*models.User, error)
+	SetDisabledWithContext(context.Context, string, bool) (*models.User, error)
+	ClaimGuest(string, string) ([]models.Link, error)
+	ClaimGuestWithContext(context.Context, string, string) ([]models.Link, error)
+	CreateGuest() (*models.User, error)
+	CreateGuestWithContext(context.Context) (*models.User, error)
+	DeleteExpiredGuests(time.Time) ([]models.Link, error)
+	DeleteExpiredGuestsWithContext(context.Context, time.Time) ([]models.Link, error)
+	FindGuest(string) (*models.User, error)
+	FindGuestWithContext(context.Context, string) (*models.User, error)
+	SetPlan(string, string) (*models.User, error)
+	SetPlanWithContext(context.Context, string, string) (*models.User, error)
+	SetRole(string, string) (*models.User, error)
+	SetRoleWithContext(context.Context, string, string) (*models.User, error)
 }
 
 // NewUserRepository creates UserRepository repository
@@ -38,17 +70,33 @@ func NewUserRepository(db *sql.DB) UserRepositoryInterface {
 	return &repository
 }
 
-func (repository *UserRepository) queryForAUserRecord(ctx context.Context, user *models.User, statement string, args ...interface{}) error {
-	err := repository.db.QueryRowContext(ctx, statement, args...).Scan(
+// scanUser reads columns listed in userColumns into the user
+func scanUser(row scanner, user *models.User) error {
+	var disabledAt pq.NullTime
+
+	err := row.Scan(
 		&user.ID,
 		&user.Login,
 		&user.Password,
+		&user.Role,
+		&user.Plan,
 		&user.Created,
+		&disabledAt,
 	)
 
+	user.DisabledAt = nil
+
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+
 	return err
 }
 
+func (repository *UserRepository) queryForAUserRecord(ctx context.Context, user *models.User, statement string, args ...interface{}) error {
+	return scanUser(repository.db.QueryRowContext(ctx, statement, args...), user)
+}
+
 // Create saves new user object to the database
 func (repository *UserRepository) Create(user models.User) (*models.User, error) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -61,14 +109,10 @@ func (repository *UserRepository) Create(user models.User) (*models.User, error)
 func (repository *UserRepository) CreateWithContext(ctx context.Context, user models.User) (*models.User, error) {
 	statement := "insert into users (login, password) values ($1, $2) returning id, created"
 
-	log.Println("user.Login", user.Login)
-	log.Println("user.Password", user.Password)
-	log.Println("ctx", ctx)
-
 	err := repository.db.QueryRowContext(ctx, statement, user.Login, user.Password).Scan(&user.ID, &user.Created)
 
-	if err != nil && strings.Contains(err.Error(), "violates unique constraint") {
-		return nil, errors.New("User with login " + user.Login + " already exists")
+	if isUniqueViolation(err, usersLoginConstraint) {
+		return nil, ErrLoginTaken
 	}
 
 	if err != nil {
@@ -80,33 +124,246 @@ func (repository *UserRepository) CreateWithContext(ctx context.Context, user mo
 	return &user, err
 }
 
+// CreateGuest saves new guest of the anonymous visitor
+func (repository *UserRepository) CreateGuest() (*models.User, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateGuestWithContext(ctx)
+}
+
+// CreateGuestWithContext saves new guest of the anonymous visitor. Guest login is generated,
+// password is empty, so it is impossible to log in as a guest.
+func (repository *UserRepository) CreateGuestWithContext(ctx context.Context) (*models.User, error) {
+	var user models.User
+
+	statement := `
+		insert into users (login, password, role)
+		values ('guest-' || uuid_generate_v4(), '', $1)
+		returning ` + userColumns
+
+	if err := repository.queryForAUserRecord(ctx, &user, statement, models.RoleGuest); err != nil {
+		return nil, err
+	}
+
+	user.CleanPrivateFields()
+
+	return &user, nil
+}
+
+// FindGuest returns guest by id
+func (repository *UserRepository) FindGuest(ID string) (*models.User, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindGuestWithContext(ctx, ID)
+}
+
+// FindGuestWithContext returns guest by id. ErrUserNotFound is returned for the registered users
+func (repository *UserRepository) FindGuestWithContext(ctx context.Context, ID string) (*models.User, error) {
+	var user models.User
+
+	if !uuidPattern.MatchString(ID) {
+		return nil, ErrUserNotFound
+	}
+
+	statement := "select " + userColumns + " from users where id = $1 and role = $2"
+
+	if err := repository.queryForAUserRecord(ctx, &user, statement, ID, models.RoleGuest); err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+
+	user.CleanPrivateFields()
+
+	return &user, nil
+}
+
+// ClaimGuest transfers links of the guest to the user
+func (repository *UserRepository) ClaimGuest(guestID string, userID string) ([]models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.ClaimGuestWithContext(ctx, guestID, userID)
+}
+
+// ClaimGuestWithContext transfers links of the guest to the user and deletes the guest.
+// Transferred links are returned with id, code and alias only, so they could be removed from the cache.
+func (repository *UserRepository) ClaimGuestWithContext(ctx context.Context, guestID string, userID string) ([]models.Link, error) {
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// links are deleted together with the guest, so they are moved first
+	statement := `
+		update links set user_id = $3
+		where user_id = (select id from users where id = $1 and role = $2)
+		returning ` + linkKeyColumns
+	rows, err := tx.QueryContext(ctx, statement, guestID, models.RoleGuest, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := scanLinkKeys(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statement = "delete from users where id = $1 and role = $2"
+	result, err := tx.ExecContext(ctx, statement, guestID, models.RoleGuest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return nil, err
+	} else if count == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// DeleteExpiredGuests deletes guests created before the time together with their links
+func (repository *UserRepository) DeleteExpiredGuests(createdBefore time.Time) ([]models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.DeleteExpiredGuestsWithContext(ctx, createdBefore)
+}
+
+// DeleteExpiredGuestsWithContext deletes guests created before the time together with their links.
+// Cookies of such guests are expired, so their links could not be claimed anymore.
+// Deleted links are returned with id, code and alias only, so they could be removed from the cache.
+func (repository *UserRepository) DeleteExpiredGuestsWithContext(ctx context.Context, createdBefore time.Time) ([]models.Link, error) {
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// guests are locked first, so links created concurrently are not deleted by the cascade unnoticed
+	statement := "select id from users where role = $1 and created < $2 for update"
+
+	if _, err = tx.ExecContext(ctx, statement, models.RoleGuest, createdBefore); err != nil {
+		return nil, err
+	}
+
+	statement = `
+		delete from links
+		where user_id in (select id from users where role = $1 and created < $2)
+		returning ` + linkKeyColumns
+	rows, err := tx.QueryContext(ctx, statement, models.RoleGuest, createdBefore)
+
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := scanLinkKeys(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statement = "delete from users where role = $1 and created < $2"
+
+	if _, err = tx.ExecContext(ctx, statement, models.RoleGuest, createdBefore); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
 // Delete user
-func (repository *UserRepository) Delete(user models.User) error {
+func (repository *UserRepository) Delete(user models.User) ([]models.Link, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	return repository.DeleteWithContext(ctx, user)
 }
 
-// DeleteWithContext user using context
-func (repository *UserRepository) DeleteWithContext(ctx context.Context, user models.User) error {
-	statement := "delete from users where id = $1"
+// DeleteWithContext user using context. Links of the user in the shared workspaces are transferred to the workspaces,
+// workspaces where the user is the only member are deleted together with the user.
+// Deleted and transferred links are returned with id, code and alias only, so they could be removed from the cache.
+func (repository *UserRepository) DeleteWithContext(ctx context.Context, user models.User) ([]models.Link, error) {
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
 
-	result, err := repository.db.ExecContext(ctx, statement, user.ID)
+	transferred, err := leaveWorkspaces(ctx, tx, user.ID, "")
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rows, err := result.RowsAffected()
+	// links would be deleted by the cascade as well, they are deleted explicitly to return their keys
+	soleWorkspaces := `
+		select w.id from workspaces w
+		where exists (select 1 from workspace_members m where m.workspace_id = w.id and m.user_id = $1)
+		and not exists (select 1 from workspace_members m where m.workspace_id = w.id and m.user_id <> $1)
+		`
+	statement := "delete from links where user_id = $1 or workspace_id in (" + soleWorkspaces + ") returning " + linkKeyColumns
+	rows, err := tx.QueryContext(ctx, statement, user.ID)
 
 	if err != nil {
-		return err
-	} else if rows == 0 {
-		return sql.ErrNoRows
+		return nil, err
 	}
 
-	return nil
+	links, err := scanLinkKeys(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statement = "delete from workspaces where id in (" + soleWorkspaces + ")"
+
+	if _, err = tx.ExecContext(ctx, statement, user.ID); err != nil {
+		return nil, err
+	}
+
+	statement = "delete from users where id = $1"
+
+	result, err := tx.ExecContext(ctx, statement, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return nil, err
+	} else if count == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return append(links, transferred...), nil
 }
 
 // FindByID returns user by id. This is a preferable way to fetch user in most cases
@@ -121,17 +378,19 @@ func (repository *UserRepository) FindByID(ID string, opts options.Options) (*mo
 func (repository *UserRepository) FindByIDWithContext(ctx context.Context, ID string, opts options.Options) (*models.User, error) {
 	var user models.User
 
-	statement := "select id, login, password, created from users where id = $1"
+	statement := "select " + userColumns + " from users where id = $1"
 
 	err := repository.queryForAUserRecord(ctx, &user, statement, ID)
 
 	user.CleanPrivateFields()
 
 	if err != nil {
-		return nil, err
+		return nil, notFoundAs(err, ErrUserNotFound)
 	}
 
-	repository.fetchAdditionalFieldsForUser(ctx, &user, opts)
+	if err = repository.fetchAdditionalFieldsForUser(ctx, &user, opts); err != nil {
+		return nil, err
+	}
 
 	return &user, nil
 }
@@ -149,14 +408,257 @@ func (repository *UserRepository) FindByLoginWithContext(ctx context.Context, lo
 	var user models.User
 	user.Links = make([]*models.Link, 0)
 
-	statement := "select id, login, password, created from users where login = $1"
+	statement := "select " + userColumns + " from users where login = $1"
 
 	err := repository.queryForAUserRecord(ctx, &user, statement, login)
 
 	if err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+
+	return &user, nil
+}
+
+// FindCredentials returns user by id together with password hash
+func (repository *UserRepository) FindCredentials(ID string) (*models.User, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindCredentialsWithContext(ctx, ID)
+}
+
+// FindCredentialsWithContext returns user by id together with password hash.
+// It is used to confirm sensitive actions, links are not loaded.
+func (repository *UserRepository) FindCredentialsWithContext(ctx context.Context, ID string) (*models.User, error) {
+	var user models.User
+
+	statement := "select " + userColumns + " from users where id = $1"
+
+	if err := repository.queryForAUserRecord(ctx, &user, statement, ID); err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+
+	return &user, nil
+}
+
+// Update saves login of the user
+func (repository *UserRepository) Update(user models.User) (*models.User, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.UpdateWithContext(ctx, user)
+}
+
+// UpdateWithContext saves login of the user. ErrLoginTaken is returned when login is used by another user
+func (repository *UserRepository) UpdateWithContext(ctx context.Context, user models.User) (*models.User, error) {
+	statement := "update users set login = $2 where id = $1 returning " + userColumns
+
+	err := repository.queryForAUserRecord(ctx, &user, statement, user.ID, user.Login)
+
+	if isUniqueViolation(err, usersLoginConstraint) {
+		return nil, ErrLoginTaken
+	}
+
+	if err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+
+	user.CleanPrivateFields()
+
+	return &user, nil
+}
+
+// UpdatePassword saves password hash of the user and invalidates sessions
+func (repository *UserRepository) UpdatePassword(user models.User) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.UpdatePasswordWithContext(ctx, user)
+}
+
+// UpdatePasswordWithContext saves password hash of the user. Refresh tokens and reset tokens are revoked,
+// access tokens issued before the change are rejected (see TokenRepository.IsAccessTokenRevoked).
+// JWT issue time has seconds precision, so tokens issued within the same second are still accepted.
+func (repository *UserRepository) UpdatePasswordWithContext(ctx context.Context, user models.User) error {
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	statement := "update users set password = $2, tokens_valid_after = date_trunc('second', now()) where id = $1"
+	result, err := tx.ExecContext(ctx, statement, user.ID, user.Password)
+
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	statements := []string{
+		"update refresh_tokens set revoked_at = now() where user_id = $1 and revoked_at is null",
+		"update password_reset_tokens set used_at = now() where user_id = $1 and used_at is null",
+	}
+
+	for _, statement := range statements {
+		if _, err = tx.ExecContext(ctx, statement, user.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Search returns users which login contains the query
+func (repository *UserRepository) Search(query string, opts options.Options) ([]*models.User, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.SearchWithContext(ctx, query, opts)
+}
+
+// SearchWithContext returns users which login contains the query (case insensitive).
+// All users are returned for the empty query, links are not loaded.
+func (repository *UserRepository) SearchWithContext(ctx context.Context, query string, opts options.Options) ([]*models.User, error) {
+	statement := `
+		select ` + userColumns + `
+		from users
+		where login ilike '%' || $1 || '%'
+		order by created desc
+		limit $2
+		offset $3
+		`
+
+	rows, err := repository.db.QueryContext(ctx, statement, likePattern.Replace(query), opts.Limit, opts.Offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+
+	for rows.Next() {
+		var user models.User
+
+		if err = scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+
+		user.CleanPrivateFields()
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// SetDisabled disables or enables the user
+func (repository *UserRepository) SetDisabled(ID string, disabled bool) (*models.User, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.SetDisabledWithContext(ctx, ID, disabled)
+}
+
+// SetDisabledWithContext disables or enables the user. Sessions of the disabled user are revoked
+// in the same way as after the password change, API keys are rejected while the user is disabled.
+func (repository *UserRepository) SetDisabledWithContext(ctx context.Context, ID string, disabled bool) (*models.User, error) {
+	var user models.User
+
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	statement := "update users set disabled_at = null where id = $1 returning " + userColumns
+
+	if disabled {
+		statement = `
+			update users
+			set disabled_at = coalesce(disabled_at, now()), tokens_valid_after = date_trunc('second', now())
+			where id = $1
+			returning ` + userColumns
+	}
+
+	if err = scanUser(tx.QueryRowContext(ctx, statement, ID), &user); err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+
+	if disabled {
+		statement = "update refresh_tokens set revoked_at = now() where user_id = $1 and revoked_at is null"
+
+		if _, err = tx.ExecContext(ctx, statement, ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
+	user.CleanPrivateFields()
+
+	return &user, nil
+}
+
+// SetRole changes role of the user
+func (repository *UserRepository) SetRole(ID string, role string) (*models.User, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.SetRoleWithContext(ctx, ID, role)
+}
+
+// SetRoleWithContext changes role of the user. Role is a part of the access token claims,
+// so access tokens issued before the change are rejected and clients have to refresh them.
+func (repository *UserRepository) SetRoleWithContext(ctx context.Context, ID string, role string) (*models.User, error) {
+	var user models.User
+
+	statement := `
+		update users
+		set role = $2, tokens_valid_after = date_trunc('second', now())
+		where id = $1
+		returning ` + userColumns
+
+	if err := repository.queryForAUserRecord(ctx, &user, statement, ID, role); err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+
+	user.CleanPrivateFields()
+
+	return &user, nil
+}
+
+// SetPlan changes plan of the user
+func (repository *UserRepository) SetPlan(ID string, plan string) (*models.User, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.SetPlanWithContext(ctx, ID, plan)
+}
+
+// SetPlanWithContext changes plan of the user, new limits are applied immediately
+func (repository *UserRepository) SetPlanWithContext(ctx context.Context, ID string, plan string) (*models.User, error) {
+	var user models.User
+
+	statement := "update users set plan = $2 where id = $1 returning " + userColumns
+
+	if err := repository.queryForAUserRecord(ctx, &user, statement, ID, plan); err != nil {
+		return nil, notFoundAs(err, ErrUserNotFound)
+	}
+
+	user.CleanPrivateFields()
+
 	return &user, nil
 }
 
@@ -1,12 +1,12 @@
 package repository_test
 
 import (
-	"database/sql"
 	"shortener/models"
 	"shortener/models/options"
 	"shortener/repository"
 	testutils "shortener/testUtils"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -67,11 +67,19 @@ func UserTestSuite(t *testing.T, r *testutils.Repositories) {
 		})
 		require.Nil(t, err)
 
-		for range make([]struct{}, 10) {
-			_, err := r.Usages.Create(link.ID)
+		for range make([]struct{}, 5) {
+			_, err := r.Usages.Create(models.Usage{UrlID: link.ID})
 			assert.Nil(t, err)
 		}
 
+		usages := make([]models.Usage, 5)
+
+		for i := range usages {
+			usages[i] = models.Usage{UrlID: link.ID, Created: time.Now()}
+		}
+
+		assert.Nil(t, r.Usages.CreateMany(usages))
+
 		foundUser, err := r.Users.FindByID(user.ID, options.Options{
 			Limit: 25,
 		})
@@ -84,21 +92,141 @@ func UserTestSuite(t *testing.T, r *testutils.Repositories) {
 		assert.Equal(t, int64(10), fetchedLinks[0].UsagesCount)
 	})
 
+	t.Run("should find user credentials by ID", func(t *testing.T) {
+		credentials, err := r.Users.FindCredentials(user.ID)
+
+		require.Nil(t, err)
+		assert.Equal(t, login, credentials.Login)
+	})
+
+	t.Run("should update login", func(t *testing.T) {
+		newLogin, _ := shortid.Generate()
+		updatedUser, err := r.Users.Update(models.User{ID: user.ID, Login: newLogin})
+
+		require.Nil(t, err)
+		assert.Equal(t, newLogin, updatedUser.Login)
+		assert.Empty(t, updatedUser.Password, "password should not be returned")
+
+		anotherLogin, _ := shortid.Generate()
+		another, err := r.Users.Create(models.User{Login: anotherLogin, Password: "password"})
+		require.Nil(t, err)
+		defer r.Users.Delete(*another)
+
+		_, err = r.Users.Update(models.User{ID: another.ID, Login: newLogin})
+		assert.Equal(t, repository.ErrLoginTaken, err, "login of another user should not be used")
+	})
+
+	t.Run("should search users by login", func(t *testing.T) {
+		found, err := r.Users.Search(user.Login[1:], options.Options{Limit: 10})
+
+		require.Nil(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, user.ID, found[0].ID)
+		assert.Equal(t, models.RoleUser, found[0].Role, "new users should have user role")
+		assert.Empty(t, found[0].Password, "password should not be returned")
+
+		found, err = r.Users.Search("%", options.Options{Limit: 10})
+
+		require.Nil(t, err)
+		assert.Len(t, found, 0, "wildcards should be escaped")
+	})
+
+	t.Run("should change role of the user", func(t *testing.T) {
+		updatedUser, err := r.Users.SetRole(user.ID, models.RoleSupport)
+
+		require.Nil(t, err)
+		assert.Equal(t, models.RoleSupport, updatedUser.Role)
+
+		_, err = r.Users.SetRole("00000000-0000-0000-0000-000000000000", models.RoleSupport)
+		assert.Equal(t, repository.ErrUserNotFound, err)
+	})
+
+	t.Run("should disable and enable user", func(t *testing.T) {
+		disabledUser, err := r.Users.SetDisabled(user.ID, true)
+
+		require.Nil(t, err)
+		assert.True(t, disabledUser.IsDisabled())
+
+		foundUser, err := r.Users.FindByLogin(user.Login)
+		require.Nil(t, err)
+		assert.True(t, foundUser.IsDisabled())
+
+		enabledUser, err := r.Users.SetDisabled(user.ID, false)
+
+		require.Nil(t, err)
+		assert.False(t, enabledUser.IsDisabled())
+	})
+
+	t.Run("should transfer links of the guest to the user", func(t *testing.T) {
+		guest, err := r.Users.CreateGuest()
+
+		require.Nil(t, err)
+		assert.True(t, guest.IsGuest())
+
+		foundGuest, err := r.Users.FindGuest(guest.ID)
+		require.Nil(t, err)
+		assert.Equal(t, guest.ID, foundGuest.ID)
+
+		_, err = r.Users.FindGuest(user.ID)
+		assert.Equal(t, repository.ErrUserNotFound, err, "registered user should not be found as a guest")
+
+		link, err := r.Links.Create(models.Link{URL: "https://example.com", UserID: guest.ID})
+		require.Nil(t, err)
+
+		claimed, err := r.Users.ClaimGuest(guest.ID, user.ID)
+		require.Nil(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, link.ID, claimed[0].ID)
+		assert.Equal(t, link.Code, claimed[0].Code)
+
+		claimedLink, err := r.Links.FindByID(models.Link{ID: link.ID})
+		require.Nil(t, err)
+		assert.Equal(t, user.ID, claimedLink.UserID)
+
+		_, err = r.Users.FindGuest(guest.ID)
+		assert.Equal(t, repository.ErrUserNotFound, err, "claimed guest should be deleted")
+		_, err = r.Users.ClaimGuest(guest.ID, user.ID)
+		assert.Equal(t, repository.ErrUserNotFound, err)
+	})
+
+	t.Run("should delete expired guests together with their links", func(t *testing.T) {
+		guest, err := r.Users.CreateGuest()
+		require.Nil(t, err)
+
+		link, err := r.Links.Create(models.Link{URL: "https://example.com", UserID: guest.ID})
+		require.Nil(t, err)
+
+		deleted, err := r.Users.DeleteExpiredGuests(guest.Created)
+		require.Nil(t, err)
+		assert.Empty(t, deleted, "guest should not be expired yet")
+
+		deleted, err = r.Users.DeleteExpiredGuests(time.Now().Add(time.Hour))
+		require.Nil(t, err)
+		require.Len(t, deleted, 1)
+		assert.Equal(t, link.ID, deleted[0].ID)
+
+		_, err = r.Users.FindGuest(guest.ID)
+		assert.Equal(t, repository.ErrUserNotFound, err)
+
+		_, err = r.Links.FindByID(models.Link{ID: link.ID})
+		assert.Equal(t, repository.ErrLinkNotFound, err)
+	})
+
 	t.Run("should delete user", func(t *testing.T) {
-		err = r.Users.Delete(*user)
+		_, err = r.Users.Delete(*user)
 
 		assert.Nil(t, err, "should delete user without errors")
 
 		foundUser, err := r.Users.FindByID(user.ID, options.Options{})
 
 		assert.Nil(t, foundUser, "found user should be nil")
-		assert.Equal(t, sql.ErrNoRows, err)
+		assert.Equal(t, repository.ErrUserNotFound, err)
 	})
 
 	t.Run("should throw an error when try to delete nonexisting user", func(t *testing.T) {
-		err = r.Users.Delete(*user)
+		_, err = r.Users.Delete(*user)
 
-		assert.Equal(t, sql.ErrNoRows, err, "should throw an error when try to delete the nonexisting user")
+		assert.Equal(t, repository.ErrUserNotFound, err, "should throw an error when try to delete the nonexisting user")
 	})
 }
 
@@ -0,0 +1,590 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"shortener/models"
+)
+
+// WorkspaceRepository type represents repository to work with workspaces, members and invitations
+type WorkspaceRepository BaseRepository
+
+// WorkspaceRepositoryInterface interface
+type WorkspaceRepositoryInterface interface {
+	AcceptInvitation(string, string) (*models.WorkspaceMember, error)
+	AcceptInvitationWithContext(context.Context, string, string) (*models.WorkspaceMember, error)
+	Create(models.Workspace, string) (*models.Workspace, error)
+	CreateWithContext(context.Context, models.Workspace, string) (*models.Workspace, error)
+	CreateInvitation(models.WorkspaceInvitation) (*models.WorkspaceInvitation, error)
+	CreateInvitationWithContext(context.Context, models.WorkspaceInvitation) (*models.WorkspaceInvitation, error)
+	DeclineInvitation(string, string) error
+	DeclineInvitationWithContext(context.Context, string, string) error
+	Delete(models.Workspace) ([]models.Link, error)
+	DeleteWithContext(context.Context, models.Workspace) ([]models.Link, error)
+	FindAllByUser(models.User) ([]*models.Workspace, error)
+	FindAllByUserWithContext(context.Context, models.User) ([]*models.Workspace, error)
+	FindByID(string) (*models.Workspace, error)
+	FindByIDWithContext(context.Context, string) (*models.Workspace, error)
+	FindInvitationsByUser(models.User) ([]*models.WorkspaceInvitation, error)
+	FindInvitationsByUserWithContext(context.Context, models.User) ([]*models.WorkspaceInvitation, error)
+	FindMember(string, string) (*models.WorkspaceMember, error)
+	FindMemberWithContext(context.Context, string, string) (*models.WorkspaceMember, error)
+	RemoveMember(string, string) ([]models.Link, error)
+	RemoveMemberWithContext(context.Context, string, string) ([]models.Link, error)
+	UpdateMember(models.WorkspaceMember) (*models.WorkspaceMember, error)
+	UpdateMemberWithContext(context.Context, models.WorkspaceMember) (*models.WorkspaceMember, error)
+}
+
+// NewWorkspaceRepository creates workspaces repository
+func NewWorkspaceRepository(db *sql.DB) WorkspaceRepositoryInterface {
+	return &WorkspaceRepository{
+		db: db,
+	}
+}
+
+// leaveWorkspaces prepares removal of the member from the workspace (or from all workspaces when workspaceID is empty).
+// When the member is the only owner, another member (editors first, then the oldest members) becomes an owner.
+// Links of the member are transferred to the owner of the workspace, so they stay in the workspace.
+// Transferred links are returned with id, code and alias only, so they could be removed from the cache.
+func leaveWorkspaces(ctx context.Context, tx *sql.Tx, userID string, workspaceID string) ([]models.Link, error) {
+	workspace := sql.NullString{String: workspaceID, Valid: workspaceID != ""}
+	statement := `
+		update workspace_members m
+		set role = 'owner'
+		from (
+			select distinct on (s.workspace_id) s.workspace_id, s.user_id
+			from workspace_members s
+			join workspace_members leaving
+			on leaving.workspace_id = s.workspace_id and leaving.user_id = $1 and leaving.role = 'owner'
+			where s.user_id <> $1
+			and ($2::uuid is null or s.workspace_id = $2)
+			and not exists (
+				select 1 from workspace_members o
+				where o.workspace_id = s.workspace_id and o.role = 'owner' and o.user_id <> $1
+			)
+			order by s.workspace_id, s.role = 'editor' desc, s.created
+		) successor
+		where m.workspace_id = successor.workspace_id and m.user_id = successor.user_id
+		`
+
+	if _, err := tx.ExecContext(ctx, statement, userID, workspace); err != nil {
+		return nil, err
+	}
+
+	statement = `
+		update links l
+		set user_id = (
+			select o.user_id from workspace_members o
+			where o.workspace_id = l.workspace_id and o.role = 'owner' and o.user_id <> $1
+			order by o.created
+			limit 1
+		)
+		where l.user_id = $1
+		and l.workspace_id is not null
+		and ($2::uuid is null or l.workspace_id = $2)
+		and exists (
+			select 1 from workspace_members o
+			where o.workspace_id = l.workspace_id and o.role = 'owner' and o.user_id <> $1
+		)
+		returning ` + linkKeyColumns
+	rows, err := tx.QueryContext(ctx, statement, userID, workspace)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanLinkKeys(rows)
+}
+
+// AcceptInvitation adds the invited user to the workspace
+func (repository *WorkspaceRepository) AcceptInvitation(ID string, userID string) (*models.WorkspaceMember, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.AcceptInvitationWithContext(ctx, ID, userID)
+}
+
+// AcceptInvitationWithContext adds the invited user to the workspace with the role from the invitation.
+// Invitation is removed, ErrInvitationNotFound is returned for expired invitations and invitations of other users.
+func (repository *WorkspaceRepository) AcceptInvitationWithContext(ctx context.Context, ID string, userID string) (*models.WorkspaceMember, error) {
+	member := models.WorkspaceMember{UserID: userID}
+
+	if !uuidPattern.MatchString(ID) {
+		return nil, ErrInvitationNotFound
+	}
+
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	statement := `
+		delete from workspace_invitations
+		where id = $1 and user_id = $2 and expires_at > now()
+		returning workspace_id, role
+		`
+
+	if err = tx.QueryRowContext(ctx, statement, ID, userID).Scan(&member.WorkspaceID, &member.Role); err != nil {
+		return nil, notFoundAs(err, ErrInvitationNotFound)
+	}
+
+	statement = `
+		insert into workspace_members (workspace_id, user_id, role)
+		values ($1, $2, $3)
+		on conflict (workspace_id, user_id) do nothing
+		returning created
+		`
+
+	if err = tx.QueryRowContext(ctx, statement, member.WorkspaceID, member.UserID, member.Role).Scan(&member.Created); err != nil {
+		return nil, notFoundAs(err, ErrAlreadyMember)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// Create saves new workspace, the user becomes its owner
+func (repository *WorkspaceRepository) Create(workspace models.Workspace, ownerID string) (*models.Workspace, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateWithContext(ctx, workspace, ownerID)
+}
+
+// CreateWithContext saves new workspace, the user becomes its owner
+func (repository *WorkspaceRepository) CreateWithContext(ctx context.Context, workspace models.Workspace, ownerID string) (*models.Workspace, error) {
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	statement := "insert into workspaces (name) values ($1) returning id, created"
+
+	if err = tx.QueryRowContext(ctx, statement, workspace.Name).Scan(&workspace.ID, &workspace.Created); err != nil {
+		return nil, err
+	}
+
+	statement = "insert into workspace_members (workspace_id, user_id, role) values ($1, $2, $3)"
+
+	if _, err = tx.ExecContext(ctx, statement, workspace.ID, ownerID, models.WorkspaceOwner); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	workspace.Role = models.WorkspaceOwner
+
+	return &workspace, nil
+}
+
+// CreateInvitation saves invitation to the workspace
+func (repository *WorkspaceRepository) CreateInvitation(invitation models.WorkspaceInvitation) (*models.WorkspaceInvitation, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.CreateInvitationWithContext(ctx, invitation)
+}
+
+// CreateInvitationWithContext saves invitation to the workspace. Pending invitation of the same user is replaced.
+// ErrAlreadyMember is returned when the user is a member of the workspace.
+func (repository *WorkspaceRepository) CreateInvitationWithContext(ctx context.Context, invitation models.WorkspaceInvitation) (*models.WorkspaceInvitation, error) {
+	statement := `
+		insert into workspace_invitations (workspace_id, user_id, invited_by, role, expires_at)
+		select $1::uuid, $2::uuid, $3::uuid, $4::varchar, $5::timestamptz
+		where not exists (select 1 from workspace_members where workspace_id = $1 and user_id = $2)
+		on conflict (workspace_id, user_id) do update
+		set invited_by = excluded.invited_by, role = excluded.role, expires_at = excluded.expires_at, created = now()
+		returning id, created
+		`
+	invitedBy := sql.NullString{String: invitation.InvitedBy, Valid: invitation.InvitedBy != ""}
+
+	err := repository.db.QueryRowContext(
+		ctx,
+		statement,
+		invitation.WorkspaceID,
+		invitation.UserID,
+		invitedBy,
+		invitation.Role,
+		invitation.ExpiresAt,
+	).Scan(&invitation.ID, &invitation.Created)
+
+	if err != nil {
+		return nil, notFoundAs(err, ErrAlreadyMember)
+	}
+
+	return &invitation, nil
+}
+
+// DeclineInvitation removes invitation of the user
+func (repository *WorkspaceRepository) DeclineInvitation(ID string, userID string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.DeclineInvitationWithContext(ctx, ID, userID)
+}
+
+// DeclineInvitationWithContext removes invitation of the user. ErrInvitationNotFound is returned for invitations of other users
+func (repository *WorkspaceRepository) DeclineInvitationWithContext(ctx context.Context, ID string, userID string) error {
+	statement := "delete from workspace_invitations where id = $1 and user_id = $2"
+
+	if !uuidPattern.MatchString(ID) {
+		return ErrInvitationNotFound
+	}
+
+	result, err := repository.db.ExecContext(ctx, statement, ID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// Delete removes the workspace with its links
+func (repository *WorkspaceRepository) Delete(workspace models.Workspace) ([]models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.DeleteWithContext(ctx, workspace)
+}
+
+// DeleteWithContext removes the workspace with its links, members and invitations.
+// Deleted links are returned with id, code and alias only, so they could be removed from the cache.
+func (repository *WorkspaceRepository) DeleteWithContext(ctx context.Context, workspace models.Workspace) ([]models.Link, error) {
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// links would be deleted by the cascade as well, they are deleted explicitly to return their keys
+	statement := "delete from links where workspace_id = $1 returning " + linkKeyColumns
+	rows, err := tx.QueryContext(ctx, statement, workspace.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := scanLinkKeys(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, "delete from workspaces where id = $1", workspace.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if count, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if count == 0 {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// FindAllByUser returns workspaces where the user is a member
+func (repository *WorkspaceRepository) FindAllByUser(user models.User) ([]*models.Workspace, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindAllByUserWithContext(ctx, user)
+}
+
+// FindAllByUserWithContext returns workspaces where the user is a member together with the role of the user
+func (repository *WorkspaceRepository) FindAllByUserWithContext(ctx context.Context, user models.User) ([]*models.Workspace, error) {
+	statement := `
+		select w.id, w.name, w.created, m.role
+		from workspaces w
+		join workspace_members m
+		on m.workspace_id = w.id
+		where m.user_id = $1
+		order by w.created desc
+		`
+	rows, err := repository.db.QueryContext(ctx, statement, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	workspaces := make([]*models.Workspace, 0)
+
+	for rows.Next() {
+		var workspace models.Workspace
+
+		if err = rows.Scan(&workspace.ID, &workspace.Name, &workspace.Created, &workspace.Role); err != nil {
+			return nil, err
+		}
+
+		workspaces = append(workspaces, &workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+// FindByID returns workspace with its members
+func (repository *WorkspaceRepository) FindByID(ID string) (*models.Workspace, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindByIDWithContext(ctx, ID)
+}
+
+// FindByIDWithContext returns workspace with its members. ErrWorkspaceNotFound is returned for unknown id
+func (repository *WorkspaceRepository) FindByIDWithContext(ctx context.Context, ID string) (*models.Workspace, error) {
+	var workspace models.Workspace
+
+	statement := "select id, name, created from workspaces where id = $1"
+	err := repository.db.QueryRowContext(ctx, statement, ID).Scan(&workspace.ID, &workspace.Name, &workspace.Created)
+
+	if err != nil {
+		return nil, notFoundAs(err, ErrWorkspaceNotFound)
+	}
+
+	statement = `
+		select m.workspace_id, m.user_id, u.login, m.role, m.created
+		from workspace_members m
+		join users u
+		on u.id = m.user_id
+		where m.workspace_id = $1
+		order by m.created
+		`
+	rows, err := repository.db.QueryContext(ctx, statement, ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	workspace.Members = make([]*models.WorkspaceMember, 0)
+
+	for rows.Next() {
+		var member models.WorkspaceMember
+
+		if err = rows.Scan(&member.WorkspaceID, &member.UserID, &member.Login, &member.Role, &member.Created); err != nil {
+			return nil, err
+		}
+
+		workspace.Members = append(workspace.Members, &member)
+	}
+
+	return &workspace, rows.Err()
+}
+
+// FindInvitationsByUser returns pending invitations of the user
+func (repository *WorkspaceRepository) FindInvitationsByUser(user models.User) ([]*models.WorkspaceInvitation, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindInvitationsByUserWithContext(ctx, user)
+}
+
+// FindInvitationsByUserWithContext returns pending invitations of the user, expired invitations are skipped
+func (repository *WorkspaceRepository) FindInvitationsByUserWithContext(ctx context.Context, user models.User) ([]*models.WorkspaceInvitation, error) {
+	statement := `
+		select i.id, i.workspace_id, w.name, i.user_id, coalesce(i.invited_by::text, ''), i.role, i.created, i.expires_at
+		from workspace_invitations i
+		join workspaces w
+		on w.id = i.workspace_id
+		where i.user_id = $1 and i.expires_at > now()
+		order by i.created desc
+		`
+	rows, err := repository.db.QueryContext(ctx, statement, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invitations := make([]*models.WorkspaceInvitation, 0)
+
+	for rows.Next() {
+		var invitation models.WorkspaceInvitation
+
+		err = rows.Scan(
+			&invitation.ID,
+			&invitation.WorkspaceID,
+			&invitation.WorkspaceName,
+			&invitation.UserID,
+			&invitation.InvitedBy,
+			&invitation.Role,
+			&invitation.Created,
+			&invitation.ExpiresAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, &invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// FindMember returns membership of the user in the workspace
+func (repository *WorkspaceRepository) FindMember(workspaceID string, userID string) (*models.WorkspaceMember, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.FindMemberWithContext(ctx, workspaceID, userID)
+}
+
+// FindMemberWithContext returns membership of the user in the workspace.
+// ErrWorkspaceNotFound is returned when the user is not a member, so workspaces of other teams are not revealed.
+func (repository *WorkspaceRepository) FindMemberWithContext(ctx context.Context, workspaceID string, userID string) (*models.WorkspaceMember, error) {
+	member := models.WorkspaceMember{WorkspaceID: workspaceID, UserID: userID}
+	statement := "select role, created from workspace_members where workspace_id = $1 and user_id = $2"
+
+	// workspace id comes from the route, so it is checked before the query
+	if !uuidPattern.MatchString(workspaceID) {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	if err := repository.db.QueryRowContext(ctx, statement, workspaceID, userID).Scan(&member.Role, &member.Created); err != nil {
+		return nil, notFoundAs(err, ErrWorkspaceNotFound)
+	}
+
+	return &member, nil
+}
+
+// RemoveMember removes the user from the workspace
+func (repository *WorkspaceRepository) RemoveMember(workspaceID string, userID string) ([]models.Link, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.RemoveMemberWithContext(ctx, workspaceID, userID)
+}
+
+// RemoveMemberWithContext removes the user from the workspace, links of the user stay in the workspace (see leaveWorkspaces).
+// Transferred links are returned. ErrLastWorkspaceMember is returned for the last member, the workspace should be deleted instead.
+func (repository *WorkspaceRepository) RemoveMemberWithContext(ctx context.Context, workspaceID string, userID string) ([]models.Link, error) {
+	var own, others int64
+
+	if !uuidPattern.MatchString(userID) {
+		return nil, ErrMemberNotFound
+	}
+
+	tx, err := repository.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// members are locked, so concurrent removals do not leave the workspace without owners
+	statement := "select user_id from workspace_members where workspace_id = $1 for update"
+
+	if _, err = tx.ExecContext(ctx, statement, workspaceID); err != nil {
+		return nil, err
+	}
+
+	statement = `
+		select count(*) filter (where user_id = $2), count(*) filter (where user_id <> $2)
+		from workspace_members
+		where workspace_id = $1
+		`
+
+	if err = tx.QueryRowContext(ctx, statement, workspaceID, userID).Scan(&own, &others); err != nil {
+		return nil, err
+	}
+
+	if own == 0 {
+		return nil, ErrMemberNotFound
+	}
+
+	if others == 0 {
+		return nil, ErrLastWorkspaceMember
+	}
+
+	links, err := leaveWorkspaces(ctx, tx, userID, workspaceID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statement = "delete from workspace_members where workspace_id = $1 and user_id = $2"
+
+	if _, err = tx.ExecContext(ctx, statement, workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// UpdateMember changes role of the member
+func (repository *WorkspaceRepository) UpdateMember(member models.WorkspaceMember) (*models.WorkspaceMember, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	return repository.UpdateMemberWithContext(ctx, member)
+}
+
+// UpdateMemberWithContext changes role of the member. ErrLastWorkspaceOwner is returned when the last owner is demoted
+func (repository *WorkspaceRepository) UpdateMemberWithContext(ctx context.Context, member models.WorkspaceMember) (*models.WorkspaceMember, error) {
+	statement := `
+		update workspace_members m
+		set role = $3::varchar
+		where m.workspace_id = $1 and m.user_id = $2
+		and (
+			$3::varchar = 'owner'
+			or exists (select 1 from workspace_members o where o.workspace_id = $1 and o.role = 'owner' and o.user_id <> $2)
+		)
+		returning m.created
+		`
+
+	if !uuidPattern.MatchString(member.UserID) {
+		return nil, ErrMemberNotFound
+	}
+
+	err := repository.db.QueryRowContext(ctx, statement, member.WorkspaceID, member.UserID, member.Role).Scan(&member.Created)
+
+	if err == sql.ErrNoRows {
+		if _, err = repository.FindMemberWithContext(ctx, member.WorkspaceID, member.UserID); err != nil {
+			return nil, ErrMemberNotFound
+		}
+
+		return nil, ErrLastWorkspaceOwner
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
@@ -0,0 +1,134 @@
+package repository_test
+
+import (
+	"shortener/models"
+	"shortener/models/options"
+	"shortener/repository"
+	testutils "shortener/testUtils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teris-io/shortid"
+)
+
+func TestWorkspacesPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip tests for workspaces repository for unit tests")
+	}
+
+	var suite testutils.PostgresSuite
+	suite.SetupSuite()
+	defer suite.TearDownSuite()
+
+	users := repository.NewUserRepository(suite.GetDB())
+	links := repository.NewSQLLinkRepository(suite.GetDB())
+	workspaces := repository.NewWorkspaceRepository(suite.GetDB())
+	quotas := repository.NewQuotaRepository(suite.GetDB())
+
+	newUser := func() *models.User {
+		login, _ := shortid.Generate()
+		user, err := users.Create(models.User{Login: login, Password: "password"})
+		require.Nil(t, err)
+
+		return user
+	}
+
+	owner := newUser()
+	defer users.Delete(*owner)
+	editor := newUser()
+	defer users.Delete(*editor)
+
+	workspace, err := workspaces.Create(models.Workspace{Name: "Marketing"}, owner.ID)
+	require.Nil(t, err)
+	defer workspaces.Delete(*workspace)
+
+	t.Run("should make creator an owner", func(t *testing.T) {
+		member, err := workspaces.FindMember(workspace.ID, owner.ID)
+
+		require.Nil(t, err)
+		assert.Equal(t, models.WorkspaceOwner, member.Role)
+
+		_, err = workspaces.FindMember(workspace.ID, editor.ID)
+		assert.Equal(t, repository.ErrWorkspaceNotFound, err, "workspace should be hidden from other users")
+	})
+
+	t.Run("should add member by invitation", func(t *testing.T) {
+		invitation, err := workspaces.CreateInvitation(models.NewWorkspaceInvitation(workspace.ID, editor.ID, models.WorkspaceEditor))
+		require.Nil(t, err)
+
+		pending, err := workspaces.FindInvitationsByUser(*editor)
+		require.Nil(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, "Marketing", pending[0].WorkspaceName)
+
+		_, err = workspaces.AcceptInvitation(invitation.ID, owner.ID)
+		assert.Equal(t, repository.ErrInvitationNotFound, err, "invitation of another user should not be accepted")
+
+		member, err := workspaces.AcceptInvitation(invitation.ID, editor.ID)
+		require.Nil(t, err)
+		assert.Equal(t, models.WorkspaceEditor, member.Role)
+
+		_, err = workspaces.CreateInvitation(models.NewWorkspaceInvitation(workspace.ID, editor.ID, models.WorkspaceViewer))
+		assert.Equal(t, repository.ErrAlreadyMember, err)
+	})
+
+	t.Run("should keep at least one owner", func(t *testing.T) {
+		_, err := workspaces.UpdateMember(models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: owner.ID, Role: models.WorkspaceViewer})
+
+		assert.Equal(t, repository.ErrLastWorkspaceOwner, err)
+	})
+
+	t.Run("should transfer links of the member who leaves", func(t *testing.T) {
+		link, err := links.Create(models.Link{URL: "https://example.com", UserID: editor.ID, WorkspaceID: workspace.ID})
+		require.Nil(t, err)
+
+		found, err := links.FindAllByWorkspace(*workspace, options.Options{Limit: 10})
+		require.Nil(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, link.ID, found[0].ID)
+
+		transferredLinks, err := workspaces.RemoveMember(workspace.ID, editor.ID)
+		require.Nil(t, err)
+		require.Len(t, transferredLinks, 1)
+		assert.Equal(t, link.ID, transferredLinks[0].ID)
+
+		transferred, err := links.FindByID(models.Link{ID: link.ID})
+		require.Nil(t, err)
+		assert.Equal(t, owner.ID, transferred.UserID)
+		assert.Equal(t, workspace.ID, transferred.WorkspaceID)
+
+		personal, err := links.FindAllByUser(*owner, options.Options{Limit: 10})
+		require.Nil(t, err)
+		assert.Empty(t, personal, "transferred link should not be listed as a personal link of the owner")
+
+		usage, err := quotas.Usage(*owner)
+		require.Nil(t, err)
+		assert.Equal(t, int64(0), usage.Links.Used, "transferred link should not count toward the personal quota of the owner")
+	})
+
+	t.Run("should not remove the last member", func(t *testing.T) {
+		_, err := workspaces.RemoveMember(workspace.ID, owner.ID)
+		assert.Equal(t, repository.ErrLastWorkspaceMember, err)
+	})
+
+	t.Run("should return links of the deleted workspace", func(t *testing.T) {
+		deletedWorkspace, err := workspaces.Create(models.Workspace{Name: "Sales"}, owner.ID)
+		require.Nil(t, err)
+
+		link, err := links.Create(models.Link{URL: "https://example.com", UserID: owner.ID, WorkspaceID: deletedWorkspace.ID})
+		require.Nil(t, err)
+
+		deleted, err := workspaces.Delete(*deletedWorkspace)
+		require.Nil(t, err)
+		require.Len(t, deleted, 1)
+		assert.Equal(t, link.ID, deleted[0].ID)
+		assert.Equal(t, link.Code, deleted[0].Code)
+
+		_, err = links.FindByID(models.Link{ID: link.ID})
+		assert.Equal(t, repository.ErrLinkNotFound, err)
+
+		_, err = workspaces.Delete(*deletedWorkspace)
+		assert.Equal(t, repository.ErrWorkspaceNotFound, err)
+	})
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"shortener/configuration"
+	"shortener/limiter"
+	"shortener/logger"
+	"shortener/models"
+	"shortener/utils"
+	"strconv"
+	"time"
+)
+
+// RateLimitExceededCode is returned (with 429 status and Retry-After header) when the budget of the route is spent
+const RateLimitExceededCode = "rate_limit_exceeded"
+
+// RateLimits are budgets of the routes. Buckets are kept in the store, so limits are shared by the routers
+// (and by the instances when the store is shared). Rate with zero limit disables the budget.
+type RateLimits struct {
+	Links     limiter.Rate
+	Redirects limiter.Rate
+	Login     limiter.Rate
+	store     limiter.Store
+}
+
+// NewRateLimits creates budgets from the configuration
+func NewRateLimits(store limiter.Store) *RateLimits {
+	config := configuration.GetConfiguration()
+	period := time.Duration(config.RateLimitPeriod) * time.Second
+
+	return &RateLimits{
+		Links:     limiter.Rate{Limit: config.RateLimitLinks, Period: period},
+		Redirects: limiter.Rate{Limit: config.RateLimitRedirects, Period: period},
+		Login:     limiter.Rate{Limit: config.RateLimitLogin, Period: period},
+		store:     store,
+	}
+}
+
+// rateLimitKey returns id of the user. Shared anonymous user and guests are limited by client IP,
+// so dropping the guest cookie does not reset the budget
+func rateLimitKey(r *http.Request) string {
+	user, err := models.NewUserFromContext(r.Context())
+
+	if err != nil || user.Login == configuration.GetConfiguration().AnonUserLogin || user.IsGuest() {
+		return "ip:" + utils.ClientIP(r)
+	}
+
+	return "user:" + user.ID
+}
+
+// seconds rounds duration up, so clients do not retry too early
+func seconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
+
+// withRateLimit rejects requests over the budget with 429. RateLimit-* headers are set for every request.
+// Requests are allowed when the store is not available, so rate limiting does not break the service.
+func (limits *RateLimits) withRateLimit(name string, rate limiter.Rate, handler http.HandlerFunc) http.HandlerFunc {
+	if limits == nil || rate.Limit <= 0 || rate.Period <= 0 {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := limits.store.Take(r.Context(), name+":"+rateLimitKey(r), rate)
+
+		if err != nil {
+			logger.FromContext(r.Context()).WithError(err).Warn("unable to check rate limit")
+			handler(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(result.Reset))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", seconds(result.RetryAfter))
+			utils.RespondWithError(&w, http.StatusTooManyRequests, models.NewErrorWithCode(RateLimitExceededCode, "Too many requests, please try again later"))
+			return
+		}
+
+		handler(w, r)
+	}
+}
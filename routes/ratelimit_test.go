@@ -0,0 +1,50 @@
+package routes_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"shortener/limiter"
+	"shortener/repository"
+	"shortener/routes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginIsRateLimited(t *testing.T) {
+	var db *sql.DB
+
+	rateLimits := routes.NewRateLimits(limiter.NewMemoryStore())
+	rateLimits.Login = limiter.Rate{Limit: 1, Period: time.Minute}
+
+	router := mux.NewRouter()
+	require.Nil(t, routes.AddOpenRoutes(router, db, repository.NewSQLLinkRepository(db), rateLimits))
+
+	login := func(remoteAddr string) *httptest.ResponseRecorder {
+		// invalid body is rejected before the database is used
+		r := httptest.NewRequest("POST", "/users/token", strings.NewReader("{"))
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := login("192.0.2.1:1234")
+	assert.NotEqual(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = login("192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "request over the budget should be rejected")
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), routes.RateLimitExceededCode)
+
+	w = login("192.0.2.2:1234")
+	assert.NotEqual(t, http.StatusTooManyRequests, w.Code, "clients should be limited independently")
+}
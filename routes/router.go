@@ -3,35 +3,198 @@ package routes
 import (
 	"database/sql"
 	"errors"
-	"log"
+	"net/http"
 	"shortener/controllers"
+	"shortener/logger"
+	"shortener/migrator"
+	"shortener/models"
+	"shortener/repository"
+	"shortener/tracking"
+	"shortener/utils"
 
 	"github.com/gorilla/mux"
 )
 
 // AddOpenRoutes adds open routes to the router (gorilla mux)
-// Basically we need it only for the sign in and sign up pages
+// Basically we need it only for the sign in and sign up pages.
+// Arguments are database connection, links repository and optional rate limits
 func AddOpenRoutes(router *mux.Router, args ...interface{}) error {
-	if len(args) == 0 {
-		return errors.New("Database connection is missing")
+	if len(args) < 2 {
+		return errors.New("Database connection or links repository is missing")
 	}
 
 	db, ok := args[0].(*sql.DB)
 
 	if !ok {
-		log.Fatalln("Wrong parameters in the AddOpenRoutes function")
+		logger.Log.Fatal("Wrong parameters in the AddOpenRoutes function")
+	}
+
+	linkRepository, ok := args[1].(repository.LinksRepositoryInterface)
+
+	if !ok {
+		logger.Log.Fatal("Wrong parameters in the AddOpenRoutes function")
 	}
 
-	userController := controllers.NewUserController(db)
+	rateLimits := rateLimitsFromArgs(args, 2)
+	userController := controllers.NewUserController(db, linkRepository)
 
 	router.HandleFunc("/users", userController.Create).Methods("POST")
-	router.HandleFunc("/users/token", userController.Authorize).Methods("POST")
+	router.HandleFunc("/users/token", rateLimits.withRateLimit("login", rateLimits.Login, userController.Authorize)).Methods("POST")
+	router.HandleFunc("/users/password/reset", userController.RequestPasswordReset).Methods("POST")
+	router.HandleFunc("/users/password/reset/confirm", userController.ResetPassword).Methods("POST")
+
+	return nil
+}
+
+// AddTokenRoutes adds refresh of the tokens to the router (gorilla mux)
+// It should be registered before any authorization middleware, clients refresh tokens
+// when the access token is expired and could still send it in the Authorization header.
+// Arguments are database connection and links repository
+func AddTokenRoutes(router *mux.Router, args ...interface{}) error {
+	if len(args) < 2 {
+		return errors.New("Database connection or links repository is missing")
+	}
+
+	db, ok := args[0].(*sql.DB)
+
+	if !ok {
+		logger.Log.Fatal("Wrong parameters in the AddTokenRoutes function")
+	}
+
+	linkRepository, ok := args[1].(repository.LinksRepositoryInterface)
+
+	if !ok {
+		logger.Log.Fatal("Wrong parameters in the AddTokenRoutes function")
+	}
+
+	userController := controllers.NewUserController(db, linkRepository)
+	router.HandleFunc("/users/token/refresh", userController.Refresh).Methods("POST")
 
 	return nil
 }
 
 // AddProtectedRoutes adds protected routes to the router (gorilla mux)
+// Arguments are database connection, usages writer, links repository and optional rate limits
 func AddProtectedRoutes(router *mux.Router, args ...interface{}) error {
+	if len(args) < 3 {
+		return errors.New("Database connection, usages writer or links repository is missing")
+	}
+
+	db, ok := args[0].(*sql.DB)
+
+	if !ok {
+		logger.Log.Fatal("Wrong parameters in the AddProtectedRoutes function")
+	}
+
+	usageWriter, ok := args[1].(*tracking.Writer)
+
+	if !ok {
+		logger.Log.Fatal("Wrong parameters in the AddProtectedRoutes function")
+	}
+
+	linkRepository, ok := args[2].(repository.LinksRepositoryInterface)
+
+	if !ok {
+		logger.Log.Fatal("Wrong parameters in the AddProtectedRoutes function")
+	}
+
+	rateLimits := rateLimitsFromArgs(args, 3)
+	linkController := controllers.NewLinkController(db, linkRepository, usageWriter)
+	redirect := rateLimits.withRateLimit("redirects", rateLimits.Redirects, linkController.FetchByID)
+	router.HandleFunc("/l", rateLimits.withRateLimit("links", rateLimits.Links, withScope(models.ScopeLinksWrite, linkController.Create))).Methods("POST")
+	router.HandleFunc("/l/{id}", redirect).Methods("GET")
+	// password form of the protected links is submitted to the link itself
+	router.HandleFunc("/l/{id}", redirect).Methods("POST")
+	router.HandleFunc("/l/{id}", withScope(models.ScopeLinksWrite, linkController.Update)).Methods("PATCH")
+	router.HandleFunc("/l/{id}", withScope(models.ScopeLinksWrite, linkController.Delete)).Methods("DELETE")
+	router.HandleFunc("/l/{id}/stats", withScope(models.ScopeStatsRead, linkController.Stats)).Methods("GET")
+	router.HandleFunc("/l", withScope(models.ScopeLinksRead, linkController.List)).Methods("GET")
+
+	userController := controllers.NewUserController(db, linkRepository)
+	router.HandleFunc("/users/logout", userController.Logout).Methods("POST")
+	router.HandleFunc("/users/me", withScope(models.ScopeLinksRead, userController.Me)).Methods("GET")
+	router.HandleFunc("/users/me", userController.UpdateMe).Methods("PATCH")
+	router.HandleFunc("/users/me", userController.DeleteMe).Methods("DELETE")
+	router.HandleFunc("/users/me/usage", withScope(models.ScopeLinksRead, userController.Usage)).Methods("GET")
+	router.HandleFunc("/users/me/password", userController.ChangePassword).Methods("POST")
+
+	apiKeyController := controllers.NewAPIKeyController(db)
+	router.HandleFunc("/users/keys", apiKeyController.Create).Methods("POST")
+	router.HandleFunc("/users/keys", apiKeyController.List).Methods("GET")
+	router.HandleFunc("/users/keys/{id}", apiKeyController.Revoke).Methods("DELETE")
+
+	workspaceController := controllers.NewWorkspaceController(db, linkRepository)
+	router.HandleFunc("/workspaces", workspaceController.Create).Methods("POST")
+	router.HandleFunc("/workspaces", withScope(models.ScopeLinksRead, workspaceController.List)).Methods("GET")
+	router.HandleFunc("/workspaces/{id}", withScope(models.ScopeLinksRead, workspaceController.FetchByID)).Methods("GET")
+	router.HandleFunc("/workspaces/{id}", workspaceController.Delete).Methods("DELETE")
+	router.HandleFunc("/workspaces/{id}/links", withScope(models.ScopeLinksRead, workspaceController.Links)).Methods("GET")
+	router.HandleFunc("/workspaces/{id}/invitations", workspaceController.Invite).Methods("POST")
+	router.HandleFunc("/workspaces/{id}/members/{userId}", workspaceController.UpdateMember).Methods("PATCH")
+	router.HandleFunc("/workspaces/{id}/members/{userId}", workspaceController.RemoveMember).Methods("DELETE")
+	router.HandleFunc("/users/me/invitations", workspaceController.Invitations).Methods("GET")
+	router.HandleFunc("/users/me/invitations/{id}/accept", workspaceController.AcceptInvitation).Methods("POST")
+	router.HandleFunc("/users/me/invitations/{id}", workspaceController.DeclineInvitation).Methods("DELETE")
+
+	// support role has read-only access to the administration routes
+	adminController := controllers.NewAdminController(db, linkRepository)
+	router.HandleFunc("/admin/users", withRole(models.RoleSupport, adminController.ListUsers)).Methods("GET")
+	router.HandleFunc("/admin/users/{id}", withRole(models.RoleSupport, adminController.FetchUser)).Methods("GET")
+	router.HandleFunc("/admin/users/{id}/disable", withRole(models.RoleAdmin, adminController.DisableUser)).Methods("POST")
+	router.HandleFunc("/admin/users/{id}/enable", withRole(models.RoleAdmin, adminController.EnableUser)).Methods("POST")
+	router.HandleFunc("/admin/users/{id}/role", withRole(models.RoleAdmin, adminController.UpdateRole)).Methods("PUT")
+	router.HandleFunc("/admin/users/{id}/plan", withRole(models.RoleAdmin, adminController.UpdatePlan)).Methods("PUT")
+	router.HandleFunc("/admin/links/{id}", withRole(models.RoleSupport, adminController.FetchLink)).Methods("GET")
+	router.HandleFunc("/admin/links/{id}", withRole(models.RoleAdmin, adminController.DeleteLink)).Methods("DELETE")
+	router.HandleFunc("/admin/audit", withRole(models.RoleAdmin, adminController.ListAudit)).Methods("GET")
+	return nil
+}
+
+// rateLimitsFromArgs returns rate limits passed at the index, nil (no limits) is returned when they are missing
+func rateLimitsFromArgs(args []interface{}, index int) *RateLimits {
+	if len(args) <= index {
+		return nil
+	}
+
+	rateLimits, ok := args[index].(*RateLimits)
+
+	if !ok {
+		logger.Log.Fatal("Wrong rate limits in the routes arguments")
+	}
+
+	return rateLimits
+}
+
+// withScope rejects requests which are authorized by API keys without the scope
+func withScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !models.HasScopeInContext(r.Context(), scope) {
+			utils.RespondWithError(&w, http.StatusForbidden, models.NewErrorWithCode("insufficient_scope", "API key does not have "+scope+" scope"))
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// withRole rejects requests of the users without the role. Role is taken from the access token claims,
+// API keys could not be used for administration even when their owner has the role
+func withRole(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := models.NewUserFromContext(r.Context())
+
+		if err != nil || models.NewAPIKeyFromContext(r.Context()) != nil || !user.HasRole(role) {
+			utils.RespondWithDomainError(&w, repository.ErrInsufficientRole)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// AddHealthRoutes adds liveness and readiness probes to the router (gorilla mux)
+// They should be registered before any authorization middleware
+func AddHealthRoutes(router *mux.Router, args ...interface{}) error {
 	if len(args) == 0 {
 		return errors.New("Database connection is missing")
 	}
@@ -39,12 +202,17 @@ func AddProtectedRoutes(router *mux.Router, args ...interface{}) error {
 	db, ok := args[0].(*sql.DB)
 
 	if !ok {
-		log.Fatalln("Wrong parameters in the AddOpenRoutes function")
+		logger.Log.Fatal("Wrong parameters in the AddHealthRoutes function")
 	}
 
-	linkController := controllers.NewLinkController(db)
-	router.HandleFunc("/l", linkController.Create).Methods("POST")
-	router.HandleFunc("/l/{id}", linkController.FetchByID).Methods("GET")
-	router.HandleFunc("/l", linkController.List).Methods("GET")
+	healthController, err := controllers.NewHealthController(db, migrator.Directory)
+
+	if err != nil {
+		return err
+	}
+
+	router.HandleFunc("/healthz", healthController.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", healthController.Readiness).Methods("GET")
+
 	return nil
 }
@@ -0,0 +1,42 @@
+package routes_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"shortener/repository"
+	"shortener/routes"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshIgnoresExpiredAccessToken(t *testing.T) {
+	var db *sql.DB
+
+	// routers are composed like in the main: requests with the Authorization header are routed
+	// to the subrouter which rejects invalid tokens
+	router := mux.NewRouter()
+	require.Nil(t, routes.AddTokenRoutes(router, db, repository.NewSQLLinkRepository(db)))
+
+	authorized := router.MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
+		return r.Header.Get("Authorization") != ""
+	}).Subrouter()
+	authorized.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	})
+	authorized.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	// invalid body is rejected before the database is used
+	r := httptest.NewRequest("POST", "/users/token/refresh", strings.NewReader("{"))
+	r.Header.Set("Authorization", "Bearer expired")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code, "refresh should be handled regardless of the access token")
+}
@@ -0,0 +1,177 @@
+package shortcode
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"shortener/configuration"
+)
+
+// RandomMode is a mode when codes are generated randomly
+const RandomMode = "random"
+
+// SequentialMode is a mode when codes are derived from the database sequence
+const SequentialMode = "sequential"
+
+// multiplier is used to scatter sequential values across the code space.
+// It is a prime number bigger than any ASCII alphabet, so it is coprime with the code space.
+const multiplier = 1000000007
+
+// Generator interface is used to create short codes for the links
+type Generator interface {
+	// Generate returns new code. Sequence is used only by sequential generators
+	Generate(sequence int64) (string, error)
+	// Sequential reports whether generator requires value from the database sequence
+	Sequential() bool
+}
+
+// RandomGenerator generates random codes with fixed length
+type RandomGenerator struct {
+	alphabet []byte
+	length   int
+}
+
+// SequentialGenerator converts sequence values to codes (hashids-like).
+// Codes are unique without collisions but they grow when sequence exceeds code space.
+type SequentialGenerator struct {
+	alphabet []byte
+	length   int
+}
+
+func validate(alphabet string, length int) error {
+	if len(alphabet) < 2 {
+		return errors.New("Alphabet should contain at least two characters")
+	}
+
+	seen := make(map[rune]bool)
+
+	for _, char := range alphabet {
+		if char > 127 {
+			return errors.New("Alphabet should contain only ASCII characters")
+		}
+
+		if seen[char] {
+			return errors.New("Alphabet should not contain duplicated characters")
+		}
+
+		seen[char] = true
+	}
+
+	if length <= 0 {
+		return errors.New("Code length should be positive")
+	}
+
+	return nil
+}
+
+// NewRandomGenerator creates RandomGenerator
+func NewRandomGenerator(alphabet string, length int) (*RandomGenerator, error) {
+	if err := validate(alphabet, length); err != nil {
+		return nil, err
+	}
+
+	return &RandomGenerator{alphabet: []byte(alphabet), length: length}, nil
+}
+
+// NewSequentialGenerator creates SequentialGenerator. Alphabet is shuffled using salt
+// so codes are not predictable without knowing it.
+func NewSequentialGenerator(alphabet string, length int, salt string) (*SequentialGenerator, error) {
+	if err := validate(alphabet, length); err != nil {
+		return nil, err
+	}
+
+	return &SequentialGenerator{alphabet: shuffle([]byte(alphabet), salt), length: length}, nil
+}
+
+// NewGeneratorFromConfiguration creates generator using application configuration
+func NewGeneratorFromConfiguration() (Generator, error) {
+	config := configuration.GetConfiguration()
+
+	switch config.CodeMode {
+	case RandomMode:
+		return NewRandomGenerator(config.CodeAlphabet, config.CodeLength)
+	case SequentialMode:
+		return NewSequentialGenerator(config.CodeAlphabet, config.CodeLength, config.CodeSalt)
+	default:
+		return nil, errors.New("Unknown code mode " + config.CodeMode)
+	}
+}
+
+// Generate returns random code
+func (g *RandomGenerator) Generate(sequence int64) (string, error) {
+	code := make([]byte, g.length)
+	max := big.NewInt(int64(len(g.alphabet)))
+
+	for i := range code {
+		index, err := rand.Int(rand.Reader, max)
+
+		if err != nil {
+			return "", err
+		}
+
+		code[i] = g.alphabet[index.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// Sequential returns false because random generator does not depend on the sequence
+func (g *RandomGenerator) Sequential() bool {
+	return false
+}
+
+// Generate returns code for the sequence value
+func (g *SequentialGenerator) Generate(sequence int64) (string, error) {
+	if sequence < 0 {
+		return "", errors.New("Sequence value should not be negative")
+	}
+
+	base := big.NewInt(int64(len(g.alphabet)))
+	value := big.NewInt(sequence)
+	length := g.length
+	space := new(big.Int).Exp(base, big.NewInt(int64(length)), nil)
+
+	for value.Cmp(space) >= 0 {
+		length++
+		space.Mul(space, base)
+	}
+
+	// multiplication by the number coprime with the code space is a bijection,
+	// so neighbouring sequence values produce unrelated codes
+	value.Mul(value, big.NewInt(multiplier))
+	value.Mod(value, space)
+
+	code := make([]byte, length)
+	remainder := new(big.Int)
+
+	for i := length - 1; i >= 0; i-- {
+		value.DivMod(value, base, remainder)
+		code[i] = g.alphabet[remainder.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// Sequential returns true because codes are derived from the sequence
+func (g *SequentialGenerator) Sequential() bool {
+	return true
+}
+
+// shuffle reorders alphabet deterministically using salt
+func shuffle(alphabet []byte, salt string) []byte {
+	if salt == "" {
+		return alphabet
+	}
+
+	result := make([]byte, len(alphabet))
+	copy(result, alphabet)
+	hash := sha256.Sum256([]byte(salt))
+
+	for i := len(result) - 1; i > 0; i-- {
+		j := int(hash[i%len(hash)]) % (i + 1)
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result
+}
@@ -0,0 +1,81 @@
+package shortcode_test
+
+import (
+	"shortener/shortcode"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const alphabet = "0123456789abcdef"
+
+func TestRandomGenerator(t *testing.T) {
+	generator, err := shortcode.NewRandomGenerator(alphabet, 8)
+
+	require.Nil(t, err)
+	assert.False(t, generator.Sequential())
+
+	codes := make(map[string]bool)
+
+	for i := 0; i < 100; i++ {
+		code, err := generator.Generate(0)
+
+		require.Nil(t, err)
+		assert.Len(t, code, 8)
+		assert.Empty(t, strings.Trim(code, alphabet), "code should contain only alphabet characters")
+		codes[code] = true
+	}
+
+	assert.True(t, len(codes) > 1, "codes should be random")
+}
+
+func TestSequentialGenerator(t *testing.T) {
+	generator, err := shortcode.NewSequentialGenerator(alphabet, 2, "salt")
+
+	require.Nil(t, err)
+	assert.True(t, generator.Sequential())
+
+	codes := make(map[string]bool)
+
+	// 16^2 values fit into two characters, all of them should be unique
+	for i := int64(0); i < 256; i++ {
+		code, err := generator.Generate(i)
+
+		require.Nil(t, err)
+		assert.Len(t, code, 2)
+		assert.False(t, codes[code], "code should be unique")
+		codes[code] = true
+	}
+
+	code, err := generator.Generate(256)
+
+	require.Nil(t, err)
+	assert.Len(t, code, 3, "code should grow when code space is exhausted")
+
+	_, err = generator.Generate(-1)
+
+	assert.Error(t, err)
+}
+
+func TestSequentialGeneratorSalt(t *testing.T) {
+	first, _ := shortcode.NewSequentialGenerator(alphabet, 4, "first")
+	second, _ := shortcode.NewSequentialGenerator(alphabet, 4, "second")
+
+	firstCode, _ := first.Generate(42)
+	secondCode, _ := second.Generate(42)
+
+	assert.NotEqual(t, firstCode, secondCode, "different salts should produce different codes")
+}
+
+func TestGeneratorValidation(t *testing.T) {
+	_, err := shortcode.NewRandomGenerator("a", 8)
+	assert.Error(t, err, "alphabet should contain at least two characters")
+
+	_, err = shortcode.NewRandomGenerator("aab", 8)
+	assert.Error(t, err, "alphabet should not contain duplicates")
+
+	_, err = shortcode.NewSequentialGenerator(alphabet, 0, "")
+	assert.Error(t, err, "length should be positive")
+}
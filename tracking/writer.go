@@ -0,0 +1,271 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"shortener/configuration"
+	"shortener/logger"
+	"shortener/models"
+	"shortener/repository"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BlockPolicy makes Write wait for the free space in the queue (backpressure)
+const BlockPolicy = "block"
+
+// DropPolicy makes Write drop usages when the queue is full
+const DropPolicy = "drop"
+
+// maxBatchSize keeps count of the statement parameters below the PostgreSQL limit
+const maxBatchSize = 20000
+
+// writeTimeout limits time of the single batch insert
+const writeTimeout = 30 * time.Second
+
+// Config represents settings of the Writer
+type Config struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	Policy        string
+}
+
+// Stats represents counters of the Writer
+type Stats struct {
+	Enqueued uint64 `json:"enqueued"`
+	Dropped  uint64 `json:"dropped"`
+	Written  uint64 `json:"written"`
+	Failed   uint64 `json:"failed"`
+	// OverQuota is a count of usages which are not saved because owner of the link exceeded clicks quota
+	OverQuota uint64 `json:"overQuota"`
+}
+
+// Writer saves usages to the database in background. Usages are queued and
+// workers insert them in batches when batch is full or flush interval is passed.
+type Writer struct {
+	repository repository.UsageRepositoryInterface
+	quota      repository.QuotaRepositoryInterface
+	config     Config
+	queue      chan models.Usage
+	// mutex guards the queue from being closed while it is written, closing wakes up blocked writers
+	mutex     sync.RWMutex
+	closing   chan struct{}
+	closeOnce sync.Once
+	workers   sync.WaitGroup
+	done      chan struct{}
+
+	enqueued uint64
+	dropped  uint64
+	written  uint64
+	failed   uint64
+	// overQuota is updated atomically like other counters
+	overQuota uint64
+}
+
+// NewConfigFromConfiguration returns writer settings from application configuration.
+// Error is returned when the queue policy is unknown
+func NewConfigFromConfiguration() (Config, error) {
+	config := configuration.GetConfiguration()
+
+	if config.UsageQueuePolicy != BlockPolicy && config.UsageQueuePolicy != DropPolicy {
+		return Config{}, errors.New("Unknown usage queue policy " + config.UsageQueuePolicy)
+	}
+
+	return Config{
+		QueueSize:     config.UsageQueueSize,
+		Workers:       config.UsageWorkers,
+		BatchSize:     config.UsageBatchSize,
+		FlushInterval: time.Duration(config.UsageFlushInterval) * time.Millisecond,
+		Policy:        config.UsageQueuePolicy,
+	}, nil
+}
+
+// NewWriter creates Writer and starts its workers. Quotas are not checked by this writer
+func NewWriter(repository repository.UsageRepositoryInterface, config Config) *Writer {
+	return NewWriterWithQuota(repository, nil, config)
+}
+
+// NewWriterWithQuota creates Writer which saves batches through the quota repository, so monthly clicks quota
+// of the link owners is consumed together with the insert. Usages over the quota are not saved.
+func NewWriterWithQuota(repository repository.UsageRepositoryInterface, quota repository.QuotaRepositoryInterface, config Config) *Writer {
+	if config.QueueSize < 0 {
+		config.QueueSize = 0
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	} else if config.BatchSize > maxBatchSize {
+		config.BatchSize = maxBatchSize
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+
+	writer := &Writer{
+		repository: repository,
+		quota:      quota,
+		config:     config,
+		queue:      make(chan models.Usage, config.QueueSize),
+		closing:    make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	writer.workers.Add(config.Workers)
+
+	for i := 0; i < config.Workers; i++ {
+		go writer.work()
+	}
+
+	go func() {
+		writer.workers.Wait()
+		close(writer.done)
+	}()
+
+	return writer
+}
+
+// Write puts usage to the queue. When queue is full usage is dropped or Write waits
+// for the free space until context is done or writer is closed depending on the policy.
+// It returns false when usage is dropped.
+func (w *Writer) Write(ctx context.Context, usage models.Usage) bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	select {
+	case <-w.closing:
+		atomic.AddUint64(&w.dropped, 1)
+		return false
+	default:
+	}
+
+	if w.config.Policy == BlockPolicy {
+		select {
+		case w.queue <- usage:
+			atomic.AddUint64(&w.enqueued, 1)
+			return true
+		case <-ctx.Done():
+		case <-w.closing:
+		}
+	} else {
+		select {
+		case w.queue <- usage:
+			atomic.AddUint64(&w.enqueued, 1)
+			return true
+		default:
+		}
+	}
+
+	atomic.AddUint64(&w.dropped, 1)
+
+	return false
+}
+
+// Close stops accepting new usages and waits until queued usages are saved.
+// Blocked writers drop their usages, so Close does not wait for them.
+// Error is returned when context is done before the queue is drained.
+func (w *Writer) Close(ctx context.Context) error {
+	w.closeOnce.Do(func() {
+		// writers are woken up before the lock is taken, so they release it promptly
+		close(w.closing)
+
+		w.mutex.Lock()
+		close(w.queue)
+		w.mutex.Unlock()
+	})
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("Usages queue is not drained: " + ctx.Err().Error())
+	}
+}
+
+// Stats returns counters of the writer
+func (w *Writer) Stats() Stats {
+	return Stats{
+		Enqueued:  atomic.LoadUint64(&w.enqueued),
+		Dropped:   atomic.LoadUint64(&w.dropped),
+		Written:   atomic.LoadUint64(&w.written),
+		Failed:    atomic.LoadUint64(&w.failed),
+		OverQuota: atomic.LoadUint64(&w.overQuota),
+	}
+}
+
+func (w *Writer) work() {
+	defer w.workers.Done()
+
+	batch := make([]models.Usage, 0, w.config.BatchSize)
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case usage, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+
+			batch = append(batch, usage)
+
+			if len(batch) >= w.config.BatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (w *Writer) flush(batch []models.Usage) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	if w.quota != nil {
+		saved, err := w.quota.SaveUsagesWithContext(ctx, batch)
+
+		if err != nil {
+			w.fail(batch, err)
+			return
+		}
+
+		atomic.AddUint64(&w.overQuota, uint64(len(batch)-len(saved)))
+		atomic.AddUint64(&w.written, uint64(len(saved)))
+
+		return
+	}
+
+	if err := w.repository.CreateManyWithContext(ctx, batch); err != nil {
+		w.fail(batch, err)
+		return
+	}
+
+	atomic.AddUint64(&w.written, uint64(len(batch)))
+}
+
+// fail counts and logs usages which could not be saved
+func (w *Writer) fail(batch []models.Usage, err error) {
+	atomic.AddUint64(&w.failed, uint64(len(batch)))
+	requestIDs := make([]string, 0, len(batch))
+
+	for _, usage := range batch {
+		requestIDs = append(requestIDs, usage.Meta.RequestID)
+	}
+
+	logger.Log.WithError(err).WithField("request_ids", requestIDs).Error("unable to save usages")
+}
@@ -0,0 +1,277 @@
+package tracking_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"shortener/configuration"
+	"shortener/models"
+	"shortener/repository"
+	"shortener/tracking"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRepository struct {
+	repository.UsageRepositoryInterface
+	mutex   sync.Mutex
+	batches [][]models.Usage
+	err     error
+	block   chan struct{}
+}
+
+func (r *fakeRepository) CreateManyWithContext(ctx context.Context, usages []models.Usage) error {
+	if r.block != nil {
+		<-r.block
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	batch := make([]models.Usage, len(usages))
+	copy(batch, usages)
+	r.batches = append(r.batches, batch)
+
+	return r.err
+}
+
+// fakeQuota saves only usages of the "allowed" link to the repository
+type fakeQuota struct {
+	repository.QuotaRepositoryInterface
+	repo *fakeRepository
+}
+
+func (q *fakeQuota) SaveUsagesWithContext(ctx context.Context, usages []models.Usage) ([]models.Usage, error) {
+	allowed := make([]models.Usage, 0, len(usages))
+
+	for _, usage := range usages {
+		if usage.UrlID == "allowed" {
+			allowed = append(allowed, usage)
+		}
+	}
+
+	return allowed, q.repo.CreateManyWithContext(ctx, allowed)
+}
+
+func (r *fakeRepository) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count := 0
+
+	for _, batch := range r.batches {
+		count += len(batch)
+	}
+
+	return count
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWriterFlushesBySize(t *testing.T) {
+	repo := &fakeRepository{}
+	writer := tracking.NewWriter(repo, tracking.Config{
+		QueueSize:     10,
+		Workers:       1,
+		BatchSize:     5,
+		FlushInterval: time.Hour,
+		Policy:        tracking.BlockPolicy,
+	})
+
+	for i := 0; i < 5; i++ {
+		assert.True(t, writer.Write(context.Background(), models.Usage{UrlID: "link"}))
+	}
+
+	waitFor(t, func() bool { return repo.count() == 5 })
+	require.Nil(t, writer.Close(context.Background()))
+
+	assert.Len(t, repo.batches, 1, "usages should be saved by a single batch")
+	assert.Equal(t, tracking.Stats{Enqueued: 5, Written: 5}, writer.Stats())
+}
+
+func TestWriterFlushesByInterval(t *testing.T) {
+	repo := &fakeRepository{}
+	writer := tracking.NewWriter(repo, tracking.Config{
+		QueueSize:     10,
+		Workers:       1,
+		BatchSize:     100,
+		FlushInterval: 20 * time.Millisecond,
+		Policy:        tracking.BlockPolicy,
+	})
+	defer writer.Close(context.Background())
+
+	writer.Write(context.Background(), models.Usage{UrlID: "link"})
+
+	waitFor(t, func() bool { return repo.count() == 1 })
+}
+
+func TestWriterDrainsOnClose(t *testing.T) {
+	repo := &fakeRepository{}
+	writer := tracking.NewWriter(repo, tracking.Config{
+		QueueSize:     100,
+		Workers:       3,
+		BatchSize:     7,
+		FlushInterval: time.Hour,
+		Policy:        tracking.BlockPolicy,
+	})
+
+	for i := 0; i < 50; i++ {
+		writer.Write(context.Background(), models.Usage{UrlID: "link"})
+	}
+
+	require.Nil(t, writer.Close(context.Background()))
+
+	assert.Equal(t, 50, repo.count(), "all queued usages should be saved")
+	assert.False(t, writer.Write(context.Background(), models.Usage{}), "closed writer should drop usages")
+}
+
+func TestWriterDropPolicy(t *testing.T) {
+	repo := &fakeRepository{block: make(chan struct{})}
+	writer := tracking.NewWriter(repo, tracking.Config{
+		QueueSize:     1,
+		Workers:       1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		Policy:        tracking.DropPolicy,
+	})
+
+	dropped := 0
+
+	for i := 0; i < 10; i++ {
+		if !writer.Write(context.Background(), models.Usage{UrlID: "link"}) {
+			dropped++
+		}
+	}
+
+	close(repo.block)
+	require.Nil(t, writer.Close(context.Background()))
+
+	stats := writer.Stats()
+
+	assert.True(t, dropped > 0, "usages should be dropped when queue is full")
+	assert.Equal(t, uint64(dropped), stats.Dropped)
+	assert.Equal(t, stats.Enqueued, stats.Written)
+}
+
+func TestWriterBlockPolicyRespectsContext(t *testing.T) {
+	repo := &fakeRepository{block: make(chan struct{})}
+	writer := tracking.NewWriter(repo, tracking.Config{
+		QueueSize:     1,
+		Workers:       1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		Policy:        tracking.BlockPolicy,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the first usage is taken by the blocked worker, the second one fills the queue
+	writer.Write(ctx, models.Usage{UrlID: "link"})
+	writer.Write(ctx, models.Usage{UrlID: "link"})
+
+	assert.False(t, writer.Write(ctx, models.Usage{UrlID: "link"}), "write should stop when context is done")
+
+	close(repo.block)
+	require.Nil(t, writer.Close(context.Background()))
+}
+
+func TestWriterCloseReleasesBlockedWriters(t *testing.T) {
+	repo := &fakeRepository{block: make(chan struct{})}
+	writer := tracking.NewWriter(repo, tracking.Config{
+		QueueSize:     1,
+		Workers:       1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		Policy:        tracking.BlockPolicy,
+	})
+
+	// the first usage is taken by the blocked worker, the second one fills the queue
+	writer.Write(context.Background(), models.Usage{UrlID: "link"})
+	writer.Write(context.Background(), models.Usage{UrlID: "link"})
+
+	written := make(chan bool)
+
+	go func() {
+		written <- writer.Write(context.Background(), models.Usage{UrlID: "link"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.NotNil(t, writer.Close(ctx), "queue should not be drained while the worker is blocked")
+
+	select {
+	case ok := <-written:
+		assert.False(t, ok, "blocked write should be dropped when writer is closed")
+	case <-time.After(time.Second):
+		t.Fatal("blocked write is not released by Close")
+	}
+
+	close(repo.block)
+	require.Nil(t, writer.Close(context.Background()))
+	assert.Equal(t, 2, repo.count())
+}
+
+func TestConfigRejectsUnknownPolicy(t *testing.T) {
+	os.Setenv("USAGE_QUEUE_POLICY", "wait")
+	defer configuration.Reload()
+	defer os.Unsetenv("USAGE_QUEUE_POLICY")
+
+	configuration.Reload()
+
+	_, err := tracking.NewConfigFromConfiguration()
+	assert.NotNil(t, err)
+}
+
+func TestWriterCountsFailures(t *testing.T) {
+	repo := &fakeRepository{err: errors.New("insert failed")}
+	writer := tracking.NewWriter(repo, tracking.Config{
+		QueueSize:     10,
+		Workers:       1,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		Policy:        tracking.BlockPolicy,
+	})
+
+	writer.Write(context.Background(), models.Usage{UrlID: "link"})
+	writer.Write(context.Background(), models.Usage{UrlID: "link"})
+
+	require.Nil(t, writer.Close(context.Background()))
+
+	assert.Equal(t, uint64(2), writer.Stats().Failed)
+}
+
+func TestWriterSkipsUsagesOverQuota(t *testing.T) {
+	repo := &fakeRepository{}
+	writer := tracking.NewWriterWithQuota(repo, &fakeQuota{repo: repo}, tracking.Config{
+		QueueSize:     10,
+		Workers:       1,
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+		Policy:        tracking.BlockPolicy,
+	})
+
+	writer.Write(context.Background(), models.Usage{UrlID: "allowed"})
+	writer.Write(context.Background(), models.Usage{UrlID: "limited"})
+	writer.Write(context.Background(), models.Usage{UrlID: "allowed"})
+
+	require.Nil(t, writer.Close(context.Background()))
+
+	assert.Equal(t, 2, repo.count())
+	assert.Equal(t, tracking.Stats{Enqueued: 3, Written: 2, OverQuota: 1}, writer.Stats())
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"net/http"
+	"shortener/logger"
+	"shortener/models"
+	"shortener/repository"
+)
+
+// statuses of the domain error kinds
+var statuses = map[repository.Kind]int{
+	repository.Internal:     http.StatusInternalServerError,
+	repository.NotFound:     http.StatusNotFound,
+	repository.Conflict:     http.StatusConflict,
+	repository.Validation:   http.StatusBadRequest,
+	repository.Forbidden:    http.StatusForbidden,
+	repository.Unauthorized: http.StatusUnauthorized,
+}
+
+// codes are used for errors without explicit code
+var codes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusGone:                "gone",
+	http.StatusTooManyRequests:     "too_many_requests",
+	http.StatusInternalServerError: "internal_error",
+	http.StatusServiceUnavailable:  "unavailable",
+}
+
+// StatusOf maps error to HTTP status
+func StatusOf(err error) int {
+	return statuses[repository.KindOf(err)]
+}
+
+// codeOf returns default code for the status
+func codeOf(status int) string {
+	if code, ok := codes[status]; ok {
+		return code
+	}
+
+	if status >= http.StatusInternalServerError {
+		return "internal_error"
+	}
+
+	return "error"
+}
+
+// RespondWithDomainError maps error to the status and sends it to the client.
+// Messages of the internal errors are logged but not sent.
+func RespondWithDomainError(w *http.ResponseWriter, err error) {
+	domainErr, ok := err.(*repository.Error)
+
+	if !ok {
+		logger.Log.WithError(err).WithField("request_id", (*w).Header().Get(logger.RequestIDHeader)).Error("internal error")
+		RespondWithError(w, http.StatusInternalServerError, models.NewError("Internal server error"))
+		return
+	}
+
+	RespondWithError(w, StatusOf(err), models.Error{
+		Code:    domainErr.Code,
+		Message: domainErr.Message,
+		Details: domainErr.Details,
+	})
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"shortener/configuration"
+	"shortener/logger"
+	"strings"
+	"sync"
+)
+
+var trustedProxies []*net.IPNet
+var trustedProxiesOnce sync.Once
+
+// ParseTrustedProxies parses comma separated list of IPs and CIDRs
+func ParseTrustedProxies(value string) []*net.IPNet {
+	var networks []*net.IPNet
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(item)
+
+		if err != nil {
+			logger.Log.WithError(err).WithField("proxy", item).Warn("invalid trusted proxy")
+			continue
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+func isTrusted(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns IP address of the client. X-Forwarded-For header is used only when
+// request comes from the trusted proxy (TRUSTED_PROXIES), addresses are checked from right to left
+// and the first untrusted one is the client.
+func ClientIP(r *http.Request) string {
+	trustedProxiesOnce.Do(func() {
+		trustedProxies = ParseTrustedProxies(configuration.GetConfiguration().TrustedProxies)
+	})
+
+	return ResolveClientIP(r, trustedProxies)
+}
+
+// ResolveClientIP returns IP address of the client using the list of trusted proxies
+func ResolveClientIP(r *http.Request, networks []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+
+	if ip == nil || !isTrusted(ip, networks) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		candidate := strings.TrimSpace(forwarded[i])
+		candidateIP := net.ParseIP(candidate)
+
+		if candidateIP == nil {
+			break
+		}
+
+		host = candidate
+
+		if !isTrusted(candidateIP, networks) {
+			break
+		}
+	}
+
+	return host
+}
@@ -0,0 +1,42 @@
+package utils_test
+
+import (
+	"net/http/httptest"
+	"shortener/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	proxies := utils.ParseTrustedProxies("10.0.0.0/8, 192.168.1.1, invalid")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		ip         string
+	}{
+		{"should use remote address without header", "1.2.3.4:1000", "", "1.2.3.4"},
+		{"should ignore header from untrusted client", "1.2.3.4:1000", "5.6.7.8", "1.2.3.4"},
+		{"should use header from trusted proxy", "10.0.0.1:1000", "5.6.7.8", "5.6.7.8"},
+		{"should skip trusted proxies in the chain", "10.0.0.1:1000", "5.6.7.8, 192.168.1.1, 10.1.1.1", "5.6.7.8"},
+		{"should not trust spoofed addresses", "10.0.0.1:1000", "9.9.9.9, 5.6.7.8", "5.6.7.8"},
+		{"should stop at invalid address", "10.0.0.1:1000", "garbage, 10.0.0.2", "10.0.0.2"},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/test", nil)
+			r.RemoteAddr = test.remoteAddr
+
+			if test.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+
+			assert.Equal(t, test.ip, utils.ResolveClientIP(r, proxies))
+		})
+	}
+}
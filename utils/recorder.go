@@ -0,0 +1,20 @@
+package utils
+
+import "net/http"
+
+// ResponseRecorder wraps http.ResponseWriter and remembers status code of the response
+type ResponseRecorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+// NewResponseRecorder creates ResponseRecorder, status is 200 until it is written
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+// WriteHeader remembers status code and sends it to the client
+func (recorder *ResponseRecorder) WriteHeader(status int) {
+	recorder.Status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
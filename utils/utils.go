@@ -2,14 +2,31 @@ package utils
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+	"shortener/logger"
 	"shortener/models"
 )
 
-// RespondWithError send an error to the client
+// RespondWithError send an error to the client. Request ID is taken from the response header
+// which is set by the request ID middleware. Code is derived from the status when it is empty.
 func RespondWithError(w *http.ResponseWriter, status int, err models.Error) {
-	log.Println(err)
+	if err.Code == "" {
+		err.Code = codeOf(status)
+	}
+
+	err.RequestID = (*w).Header().Get(logger.RequestIDHeader)
+	entry := logger.Log.WithField("status", status)
+
+	if err.RequestID != "" {
+		entry = entry.WithField("request_id", err.RequestID)
+	}
+
+	if status >= http.StatusInternalServerError {
+		entry.Error(err.Message)
+	} else {
+		entry.Debug(err.Message)
+	}
+
 	(*w).Header().Add("Content-Type", "application/json")
 	(*w).WriteHeader(status)
 	json.NewEncoder(*w).Encode(err)
@@ -27,3 +44,10 @@ func RedirectToAnotherResource(w *http.ResponseWriter, resource string) {
 	(*w).Header().Add("Location", resource)
 	(*w).WriteHeader(http.StatusMovedPermanently)
 }
+
+// RedirectTemporarily redirects client to another resource without allowing to cache the redirect
+func RedirectTemporarily(w *http.ResponseWriter, resource string) {
+	(*w).Header().Add("Location", resource)
+	(*w).Header().Add("Cache-Control", "no-store")
+	(*w).WriteHeader(http.StatusFound)
+}
@@ -0,0 +1,80 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"shortener/logger"
+	"shortener/models"
+	"shortener/repository"
+	"shortener/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondWithErrorIncludesRequestID(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	var w http.ResponseWriter = recorder
+	w.Header().Set(logger.RequestIDHeader, "request-1")
+
+	utils.RespondWithError(&w, http.StatusNotFound, models.NewError("Link is not found"))
+
+	var body models.Error
+	require.Nil(t, json.NewDecoder(recorder.Body).Decode(&body))
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "Link is not found", body.Message)
+	assert.Equal(t, "request-1", body.RequestID)
+}
+
+func TestRespondWithDomainError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"should map not found", repository.ErrLinkNotFound, http.StatusNotFound, "link_not_found"},
+		{"should map conflict", repository.ErrAliasTaken, http.StatusConflict, "alias_taken"},
+		{"should map forbidden", repository.ErrForbidden, http.StatusForbidden, "forbidden"},
+		{"should map validation", repository.NewValidationError(models.FieldError{Field: "alias", Code: "reserved", Message: "Alias is reserved"}), http.StatusBadRequest, repository.ValidationCode},
+		{"should hide internal errors", errors.New("connection refused"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			var w http.ResponseWriter = recorder
+
+			utils.RespondWithDomainError(&w, test.err)
+
+			var body models.Error
+			require.Nil(t, json.NewDecoder(recorder.Body).Decode(&body))
+
+			assert.Equal(t, test.status, recorder.Code)
+			assert.Equal(t, test.code, body.Code)
+			assert.NotContains(t, body.Message, "connection refused")
+		})
+	}
+}
+
+func TestValidationErrorDetails(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	var w http.ResponseWriter = recorder
+	fields := models.ValidationErrors{
+		{Field: "maxClicks", Code: "negative", Message: "Max clicks should not be negative"},
+		{Field: "alias", Code: "reserved", Message: "Alias is reserved"},
+	}
+
+	utils.RespondWithDomainError(&w, repository.NewValidationError(fields))
+
+	var body models.Error
+	require.Nil(t, json.NewDecoder(recorder.Body).Decode(&body))
+
+	assert.Equal(t, []models.FieldError(fields), body.Details)
+}